@@ -3,35 +3,117 @@ package api
 import (
 	"fmt"
 	"github.com/awilliams/couchdb-utils/util"
+	"strings"
+	"time"
 )
 
+// ActiveTask holds the fields of all task types. Fields which don't apply to
+// a task type are left empty. Sequences are integers in 1.x and strings in 2.x.
 type ActiveTask struct {
 	Type           string
 	Pid            string
+	Node           string // 2.x
+	User           string
 	Database       Database
 	Progress       int
 	DesignDocument DesignDoc `json:"design_document"`
 	StartedOn      int       `json:"started_on"`
-	Source         string
-	Target         string
-	Continuous     bool
+	UpdatedOn      int       `json:"updated_on"`
+	// indexer, search_indexer, database_compaction and view_compaction tasks
+	ChangesDone  int    `json:"changes_done"`
+	TotalChanges int    `json:"total_changes"`
+	Phase        string // compaction phase
+	View         int    // index of the view being compacted
+	Index        string // search index name
+	Retry        bool   // database compaction was retried
+	// replication tasks
+	Source                string
+	Target                string
+	Continuous            bool
+	DocId                 string      `json:"doc_id"`
+	ReplicationId         string      `json:"replication_id"`
+	DocsRead              int         `json:"docs_read"`
+	DocsWritten           int         `json:"docs_written"`
+	DocWriteFailures      int         `json:"doc_write_failures"`
+	MissingRevisionsFound int         `json:"missing_revisions_found"`
+	RevisionsChecked      int         `json:"revisions_checked"`
+	ChangesPending        int         `json:"changes_pending"`
+	CheckpointInterval    int         `json:"checkpoint_interval"`
+	SourceSeq             interface{} `json:"source_seq"`
+	CheckpointedSourceSeq interface{} `json:"checkpointed_source_seq"`
+	ThroughSeq            interface{} `json:"through_seq"`
 }
 
-func (a ActiveTask) PP(printer util.Printer) {
-	progress := fmt.Sprintf("%02d%%", a.Progress)
-	var addInfo string
+func (a ActiveTask) databaseName() string {
+	if a.Database.Name == nil {
+		return ""
+	}
+	return a.Database.String()
+}
+
+func (a ActiveTask) changes() string {
+	return fmt.Sprintf("%d/%d changes", a.ChangesDone, a.TotalChanges)
+}
+
+// description describes what the task is working on, one line per detail
+func (a ActiveTask) description() string {
+	var lines []string
 	switch a.Type {
 	case "replication":
-		addInfo = fmt.Sprintf("%s → %s", a.Source, a.Target)
+		addInfo := fmt.Sprintf("%s → %s", a.Source, a.Target)
 		if a.Continuous {
 			addInfo += " (continuous)"
 		}
+		lines = append(lines, addInfo)
+		if a.DocId == "" && a.ReplicationId != "" {
+			lines = append(lines, a.ReplicationId)
+		}
+		lines = append(lines,
+			fmt.Sprintf("docs read/written/failed: %d/%d/%d", a.DocsRead, a.DocsWritten, a.DocWriteFailures),
+			fmt.Sprintf("revisions checked/missing: %d/%d", a.RevisionsChecked, a.MissingRevisionsFound),
+			fmt.Sprintf("changes pending: %d", a.ChangesPending))
+		if a.CheckpointedSourceSeq != nil {
+			lines = append(lines, fmt.Sprintf("checkpointed seq: %v", seqString(a.CheckpointedSourceSeq)))
+		}
 	case "indexer":
-		addInfo = fmt.Sprintf("%s/%s", a.Database.String(), a.DesignDocument.String())
+		lines = append(lines, fmt.Sprintf("%s/%s", a.databaseName(), a.DesignDocument.String()), a.changes())
+	case "search_indexer":
+		lines = append(lines, fmt.Sprintf("%s/%s/%s", a.databaseName(), a.DesignDocument.String(), a.Index), a.changes())
+	case "database_compaction":
+		addInfo := a.databaseName()
+		if a.Retry {
+			addInfo += " (retry)"
+		}
+		lines = append(lines, addInfo, a.changes())
+		if a.Phase != "" {
+			lines = append(lines, "phase: "+a.Phase)
+		}
+	case "view_compaction":
+		lines = append(lines, fmt.Sprintf("%s/%s", a.databaseName(), a.DesignDocument.String()), a.changes())
+		if a.Phase != "" {
+			lines = append(lines, fmt.Sprintf("phase: %s (view %d)", a.Phase, a.View))
+		}
 	default:
-		addInfo = a.Database.String()
+		lines = append(lines, a.databaseName())
+	}
+	if a.Node != "" {
+		lines = append(lines, "node: "+a.Node)
 	}
-	printer.Print("[%s %s]\n %s", progress, a.Type, addInfo)
+	return strings.Join(lines, "\n ")
+}
+
+// seqString shortens 2.x sequences, which can be very long
+func seqString(seq interface{}) string {
+	s := fmt.Sprint(seq)
+	if len(s) > 40 {
+		return s[:40] + "…"
+	}
+	return s
+}
+
+func (a ActiveTask) PP(printer util.Printer) {
+	progress := fmt.Sprintf("%02d%%", a.Progress)
+	printer.Print("[%s %s]\n %s", progress, a.Type, a.description())
 }
 
 type ActiveTasks []ActiveTask
@@ -50,6 +132,80 @@ func (a *ActiveTasks) ByType(t string) ActiveTasks {
 	return filtered
 }
 
+func (a ActiveTasks) ByDatabase(name string) ActiveTasks {
+	var filtered ActiveTasks
+	for _, activeTask := range a {
+		if activeTask.databaseName() == name {
+			filtered = append(filtered, activeTask)
+		}
+	}
+	return filtered
+}
+
+// TaskProgress is an active task with its estimated time to completion
+type TaskProgress struct {
+	ActiveTask
+	ETA    time.Duration
+	HasETA bool
+}
+
+func (t TaskProgress) PP(printer util.Printer) {
+	eta := "ETA --"
+	if t.HasETA {
+		eta = "ETA " + t.ETA.String()
+	}
+	printer.Print("%s %3d%% %-12s %s\n %s", util.ProgressBar(t.Progress, 30), t.Progress, t.Type, eta, t.description())
+}
+
+type TaskProgresses []TaskProgress
+
+func (t TaskProgresses) PP(printer util.Printer) {
+	for _, progress := range t {
+		progress.PP(printer)
+	}
+}
+
+// EstimateProgress estimates when each task will finish from the rate of changes
+// since the previous sample of the same task (matched by pid), or since the task
+// started if there is no previous sample. Only tasks reporting total_changes have an ETA.
+func (a ActiveTasks) EstimateProgress(prev ActiveTasks) TaskProgresses {
+	previous := make(map[string]ActiveTask)
+	for _, task := range prev {
+		previous[task.Type+task.Pid] = task
+	}
+	progresses := make(TaskProgresses, len(a))
+	for i, task := range a {
+		progresses[i].ActiveTask = task
+		remaining := task.TotalChanges - task.ChangesDone
+		if task.TotalChanges == 0 || remaining < 0 {
+			continue
+		}
+		done, elapsed := task.ChangesDone, task.UpdatedOn-task.StartedOn
+		if p, found := previous[task.Type+task.Pid]; found && p.UpdatedOn < task.UpdatedOn && p.ChangesDone < task.ChangesDone {
+			done, elapsed = task.ChangesDone-p.ChangesDone, task.UpdatedOn-p.UpdatedOn
+		}
+		if done <= 0 || elapsed <= 0 {
+			continue
+		}
+		secondsLeft := float64(remaining) * float64(elapsed) / float64(done)
+		progresses[i].ETA = time.Duration(secondsLeft) * time.Second
+		progresses[i].HasETA = true
+	}
+	return progresses
+}
+
+// TransientReplications returns the replications started with /_replicate,
+// which unlike those of _replicator docs have no doc_id
+func (a ActiveTasks) TransientReplications() ActiveTasks {
+	var filtered ActiveTasks
+	for _, activeTask := range a.ByType("replication") {
+		if activeTask.DocId == "" {
+			filtered = append(filtered, activeTask)
+		}
+	}
+	return filtered
+}
+
 func (a ActiveTasks) PP(printer util.Printer) {
 	for _, activeTask := range a {
 		activeTask.PP(printer)
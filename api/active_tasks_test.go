@@ -0,0 +1,70 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransientReplications(t *testing.T) {
+	body := `[
+{"type":"replication","doc_id":"rep1","replication_id":"aaa+continuous","source":"a","target":"b"},
+{"type":"replication","replication_id":"bbb+continuous","source":"c","target":"d","continuous":true},
+{"type":"indexer","database":"db","design_document":"_design/x"}
+]`
+	ts, couchdb := newTestingServer(200, body)
+	defer ts.Close()
+	activeTasks, err := couchdb.GetActiveTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transient := activeTasks.TransientReplications()
+	if len(transient) != 1 {
+		t.Fatalf("Expected 1 transient replication, Actual: %d", len(transient))
+	}
+	if transient[0].ReplicationId != "bbb+continuous" {
+		t.Fatalf("Expected: %s, Actual: %s", "bbb+continuous", transient[0].ReplicationId)
+	}
+}
+
+func TestEstimateProgress(t *testing.T) {
+	prev := ActiveTasks{{Type: "indexer", Pid: "<0.1.0>", ChangesDone: 100, TotalChanges: 1000, StartedOn: 0, UpdatedOn: 100}}
+	activeTasks := ActiveTasks{
+		{Type: "indexer", Pid: "<0.1.0>", ChangesDone: 200, TotalChanges: 1000, StartedOn: 0, UpdatedOn: 110},
+		{Type: "indexer", Pid: "<0.2.0>", ChangesDone: 50, TotalChanges: 100, StartedOn: 0, UpdatedOn: 10},
+		{Type: "replication", Pid: "<0.3.0>"},
+	}
+	progresses := activeTasks.EstimateProgress(prev)
+	// 100 changes in 10s since the previous sample, 800 left
+	if !progresses[0].HasETA || progresses[0].ETA != 80*time.Second {
+		t.Fatalf("ETA incorrect. Expected: %v, Actual: %v", 80*time.Second, progresses[0].ETA)
+	}
+	// no previous sample, 50 changes in 10s since starting, 50 left
+	if !progresses[1].HasETA || progresses[1].ETA != 10*time.Second {
+		t.Fatalf("ETA incorrect. Expected: %v, Actual: %v", 10*time.Second, progresses[1].ETA)
+	}
+	if progresses[2].HasETA {
+		t.Fatal("Tasks without total_changes should have no ETA")
+	}
+}
+
+func TestActiveTaskDescription(t *testing.T) {
+	body := `[
+{"type":"view_compaction","database":"db","design_document":"_design/x","phase":"view","view":2,"changes_done":5,"total_changes":10,"node":"couchdb@node1"},
+{"type":"replication","source":"http://a/db","target":"db","docs_read":3,"docs_written":2,"doc_write_failures":1,"revisions_checked":4,"missing_revisions_found":3,"changes_pending":7,"checkpointed_source_seq":12,"doc_id":"rep"}
+]`
+	ts, couchdb := newTestingServer(200, body)
+	defer ts.Close()
+	activeTasks, err := couchdb.GetActiveTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"db/x\n 5/10 changes\n phase: view (view 2)\n node: couchdb@node1",
+		"http://a/db → db\n docs read/written/failed: 3/2/1\n revisions checked/missing: 4/3\n changes pending: 7\n checkpointed seq: 12",
+	}
+	for i, e := range expected {
+		if description := activeTasks[i].description(); description != e {
+			t.Fatalf("Description incorrect. Expected:\n%s\nActual:\n%s", e, description)
+		}
+	}
+}
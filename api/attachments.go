@@ -0,0 +1,107 @@
+package api
+
+import (
+	"fmt"
+	"github.com/awilliams/couchdb-utils/util"
+	"io"
+	"net/url"
+	"sort"
+)
+
+type Attachment struct {
+	DocId       string `json:"-"`
+	Name        string `json:"-"`
+	ContentType string `json:"content_type"`
+	Length      int64  `json:"length"`
+	Digest      string `json:"digest"`
+}
+
+func (a Attachment) PP(printer util.Printer) {
+	printer.Print("%s\t%s\t%s\t%d", a.DocId, a.Name, a.ContentType, a.Length)
+}
+
+type Attachments []Attachment
+
+func (a Attachments) PP(printer util.Printer) {
+	for _, attachment := range a {
+		attachment.PP(printer)
+	}
+}
+
+// attachmentsDoc is a document with attachment stubs
+type attachmentsDoc struct {
+	ID          string                `json:"_id"`
+	REV         string                `json:"_rev"`
+	Attachments map[string]Attachment `json:"_attachments"`
+}
+
+func (d attachmentsDoc) attachments() Attachments {
+	var attachments Attachments
+	var names []string
+	for name := range d.Attachments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		attachment := d.Attachments[name]
+		attachment.DocId = d.ID
+		attachment.Name = name
+		attachments = append(attachments, attachment)
+	}
+	return attachments
+}
+
+func attachmentPath(db Database, docId, name string) string {
+	return fmt.Sprintf("%s/%s/%s", db.path(), escapeDocId(docId), escapeAttachmentName(name))
+}
+
+// GetAttachments returns the attachments of a document
+func (c *Couchdb) GetAttachments(db Database, docId string) (Attachments, error) {
+	doc := new(attachmentsDoc)
+	err := c.getJson(doc, db.path()+"/"+escapeDocId(docId))
+	return doc.attachments(), err
+}
+
+// GetAllAttachments returns the attachments of every document in the database
+func (c *Couchdb) GetAllAttachments(db Database) (Attachments, error) {
+	var attachments Attachments
+	docs := new(struct {
+		Rows []struct {
+			Doc attachmentsDoc `json:"doc"`
+		} `json:"rows"`
+	})
+	err := c.getJson(docs, db.path()+"/_all_docs?include_docs=true")
+	if err != nil {
+		return attachments, err
+	}
+	for _, row := range docs.Rows {
+		attachments = append(attachments, row.Doc.attachments()...)
+	}
+	return attachments, nil
+}
+
+// GetAttachment returns the body of the attachment, which must be closed
+func (c *Couchdb) GetAttachment(db Database, docId, name string) (io.ReadCloser, error) {
+	return c.get(attachmentPath(db, docId, name))
+}
+
+// PutAttachment uploads size bytes of body as an attachment, creating the document if it doesn't exist
+func (c *Couchdb) PutAttachment(db Database, docId, name, contentType string, body io.Reader, size int64) error {
+	doc := new(attachmentsDoc)
+	err := c.getJson(doc, db.path()+"/"+escapeDocId(docId))
+	if IsNotFound(err) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	path := attachmentPath(db, docId, name)
+	if doc.REV != "" {
+		path += "?rev=" + url.QueryEscape(doc.REV)
+	}
+	resp, err := c.put(contentType, sizedReader{body, size}, path)
+	if err != nil {
+		return err
+	}
+	return parseJson(resp, new(interface{}))
+}
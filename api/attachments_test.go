@@ -0,0 +1,68 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEscapeDocId(t *testing.T) {
+	cases := map[string]string{
+		"plain":          "plain",
+		"a/b c":          "a%2Fb%20c",
+		"_design/my app": "_design/my%20app",
+	}
+	for id, expected := range cases {
+		if escaped := escapeDocId(id); escaped != expected {
+			t.Fatalf("escapeDocId(%s) incorrect. Expected: %s, Actual: %s", id, expected, escaped)
+		}
+	}
+}
+
+func TestPutAttachment(t *testing.T) {
+	var put *http.Request
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			put = r
+			body, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(201)
+			fmt.Fprint(w, `{"ok":true}`)
+			return
+		}
+		fmt.Fprint(w, `{"_id":"doc","_rev":"2-b"}`)
+	}))
+	defer ts.Close()
+	couchdb, _ := New(ts.URL)
+	name := "db"
+	err := couchdb.PutAttachment(Database{Name: &name}, "doc", "files/a b.pdf", "application/pdf", strings.NewReader("%PDF"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if put.URL.RequestURI() != "/db/doc/files/a%20b.pdf?rev=2-b" {
+		t.Fatalf("Path incorrect: %s", put.URL.RequestURI())
+	}
+	if put.ContentLength != 4 || string(body) != "%PDF" || put.Header.Get("Content-Type") != "application/pdf" {
+		t.Fatalf("Body incorrect: %d %s %s", put.ContentLength, body, put.Header.Get("Content-Type"))
+	}
+}
+
+func TestGetAllAttachments(t *testing.T) {
+	body := `{"rows":[
+{"id":"a","doc":{"_id":"a","_rev":"1-a","_attachments":{"z.png":{"content_type":"image/png","length":3,"stub":true},"b.pdf":{"content_type":"application/pdf","length":5,"stub":true}}}},
+{"id":"b","doc":{"_id":"b","_rev":"1-b"}}
+]}`
+	ts, couchdb := newTestingServer(200, body)
+	defer ts.Close()
+	name := "db"
+	attachments, err := couchdb.GetAllAttachments(Database{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 2 || attachments[0].Name != "b.pdf" || attachments[0].DocId != "a" || attachments[1].Length != 3 {
+		t.Fatalf("Attachments incorrect: %#v", attachments)
+	}
+}
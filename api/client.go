@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"io"
+)
+
+// Client is the interface of the operations of Couchdb, each taking a context
+// for cancellation. Depend on it rather than on Couchdb to substitute fakes in tests.
+// Errors of responses with an error status are CouchdbErrors, see IsNotFound,
+// IsConflict and IsUnauthorized.
+type Client interface {
+	GetActiveTasksContext(ctx context.Context) (ActiveTasks, error)
+	GetAttachmentsContext(ctx context.Context, db Database, docId string) (Attachments, error)
+	GetAllAttachmentsContext(ctx context.Context, db Database) (Attachments, error)
+	GetAttachmentContext(ctx context.Context, db Database, docId, name string) (io.ReadCloser, error)
+	PutAttachmentContext(ctx context.Context, db Database, docId, name, contentType string, body io.Reader, size int64) error
+	GetConfigContext(ctx context.Context) (Config, error)
+	GetConfigSectionContext(ctx context.Context, section string) (Config, error)
+	GetConfigValueContext(ctx context.Context, section, key string) (string, error)
+	SetConfigValueContext(ctx context.Context, section, key, value string) (string, error)
+	DeleteConfigValueContext(ctx context.Context, section, key string) (string, error)
+	GetDatabasesContext(ctx context.Context) (Databases, error)
+	GetDatabaseInfoContext(ctx context.Context, db Database) (DatabaseInfo, error)
+	GetViewsContext(ctx context.Context, db Database) (Views, error)
+	RefreshViewContext(ctx context.Context, view View) error
+	RefreshViewsContext(ctx context.Context, views Views) (Views, []error)
+	GetReplicatorsContext(ctx context.Context) (*Replicators, error)
+	GetReplicatorContext(ctx context.Context, id string) (*Replicator, error)
+	ReplicateContext(ctx context.Context, conf ReplicationConfig) error
+	ReplicateTransientContext(ctx context.Context, conf ReplicationConfig) (TransientReplication, error)
+	CancelTransientReplicationContext(ctx context.Context, replicationId string) error
+	ReplicateHostContext(ctx context.Context, remoteURL string, conf ReplicationConfig, opts ReplicateHostOptions) (HostReplicationOutcomes, error)
+	DeleteReplicatorContext(ctx context.Context, id string) error
+	DeleteAllReplicatorsContext(ctx context.Context) (*Replicators, error)
+	PlanIdMigrationContext(ctx context.Context, strategy IdStrategy) (ReplicationPlan, error)
+	RestartReplicatorContext(ctx context.Context, id string, sinceCheckpoint bool) error
+	PauseReplicatorContext(ctx context.Context, id string, sinceCheckpoint bool) error
+	ResumeReplicatorContext(ctx context.Context, id string) error
+	GetSecurityContext(ctx context.Context, db Database) (Security, error)
+	SetSecurityContext(ctx context.Context, security Security) error
+	AuditSecurityContext(ctx context.Context, filter DatabaseFilter) (Securities, error)
+	GetServerContext(ctx context.Context) (Server, error)
+	GetUpContext(ctx context.Context) (Up, error)
+	GetSessionContext(ctx context.Context) (Session, error)
+	GetStatsContext(ctx context.Context, sectionA, sectionB string) (Stats, error)
+	PlanTopologyContext(ctx context.Context, topology Topology) (ReplicationPlan, error)
+	ApplyPlanContext(ctx context.Context, plan ReplicationPlan, userCtx UserCtx) (ReplicationPlan, error)
+	GetUsersContext(ctx context.Context) (Users, error)
+	GetUserContext(ctx context.Context, name string) (User, error)
+	SaveUserContext(ctx context.Context, user User) error
+	DeleteUserContext(ctx context.Context, name string) error
+	SetPasswordContext(ctx context.Context, name, password string) error
+	SetRolesContext(ctx context.Context, name string, roles []string) error
+}
+
+var _ Client = (*Couchdb)(nil)
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/awilliams/couchdb-utils/util"
+	"sort"
+)
+
+// Config is the server configuration, by section and key
+type Config map[string]map[string]string
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c Config) sections() []string {
+	var sections []string
+	for section := range c {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	return sections
+}
+
+func (c Config) PP(printer util.Printer) {
+	for _, section := range c.sections() {
+		printer.Print("[%s]", section)
+		for _, key := range sortedKeys(c[section]) {
+			printer.Print("%s = %s", key, c[section][key])
+		}
+	}
+}
+
+type ConfigDiff struct {
+	Section string
+	Key     string
+	A       *string // nil if missing
+	B       *string
+}
+
+func (d ConfigDiff) PP(printer util.Printer) {
+	value := func(v *string) string {
+		if v == nil {
+			return "<missing>"
+		}
+		return *v
+	}
+	printer.Print("[%s] %s: %s → %s", d.Section, d.Key, value(d.A), value(d.B))
+}
+
+type ConfigDiffs []ConfigDiff
+
+func (d ConfigDiffs) PP(printer util.Printer) {
+	for _, diff := range d {
+		diff.PP(printer)
+	}
+}
+
+// Diff returns the keys whose values differ from, or are missing in, the other config
+func (c Config) Diff(other Config) ConfigDiffs {
+	var diffs ConfigDiffs
+	all := make(Config)
+	for _, conf := range []Config{c, other} {
+		for section, values := range conf {
+			if all[section] == nil {
+				all[section] = make(map[string]string)
+			}
+			for key := range values {
+				all[section][key] = ""
+			}
+		}
+	}
+	for _, section := range all.sections() {
+		for _, key := range sortedKeys(all[section]) {
+			a, inA := c[section][key]
+			b, inB := other[section][key]
+			if inA == inB && a == b {
+				continue
+			}
+			diff := ConfigDiff{Section: section, Key: key}
+			if inA {
+				diff.A = &a
+			}
+			if inB {
+				diff.B = &b
+			}
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs
+}
+
+// configPath returns the config path, which moved to the node in 2.x
+func (c *Couchdb) configPath(parts ...string) (string, error) {
+	server, err := c.GetServer()
+	if err != nil {
+		return "", err
+	}
+	base := "_node/_local/_config"
+	if server.Is1x() {
+		base = "_config"
+	}
+	for _, part := range parts {
+		base += "/" + escapeSegment(part)
+	}
+	return base, nil
+}
+
+func (c *Couchdb) GetConfig() (Config, error) {
+	config := make(Config)
+	path, err := c.configPath()
+	if err != nil {
+		return config, err
+	}
+	err = c.getJson(&config, path)
+	return config, err
+}
+
+func (c *Couchdb) GetConfigSection(section string) (Config, error) {
+	config := make(Config)
+	path, err := c.configPath(section)
+	if err != nil {
+		return config, err
+	}
+	values := make(map[string]string)
+	err = c.getJson(&values, path)
+	config[section] = values
+	return config, err
+}
+
+func (c *Couchdb) GetConfigValue(section, key string) (string, error) {
+	var value string
+	path, err := c.configPath(section, key)
+	if err != nil {
+		return value, err
+	}
+	err = c.getJson(&value, path)
+	return value, err
+}
+
+// SetConfigValue sets the value, returning the previous one
+func (c *Couchdb) SetConfigValue(section, key, value string) (string, error) {
+	var old string
+	path, err := c.configPath(section, key)
+	if err != nil {
+		return old, err
+	}
+	jsonBody, err := json.Marshal(value)
+	if err != nil {
+		return old, err
+	}
+	err = c.putJson(&old, bytes.NewReader(jsonBody), path)
+	return old, err
+}
+
+// DeleteConfigValue deletes the key, returning its previous value
+func (c *Couchdb) DeleteConfigValue(section, key string) (string, error) {
+	var old string
+	path, err := c.configPath(section, key)
+	if err != nil {
+		return old, err
+	}
+	err = c.deleteJson(&old, path)
+	return old, err
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestConfigDiff(t *testing.T) {
+	a := Config{
+		"httpd":   {"port": "5984", "bind_address": "0.0.0.0"},
+		"couchdb": {"max_dbs_open": "100"},
+	}
+	b := Config{
+		"httpd":   {"port": "5984", "bind_address": "127.0.0.1"},
+		"couchdb": {"max_dbs_open": "100", "uuid": "abc"},
+	}
+	diffs := a.Diff(b)
+	if len(diffs) != 2 {
+		t.Fatalf("Expected 2 diffs, Actual: %d (%#v)", len(diffs), diffs)
+	}
+	if diffs[0].Section != "couchdb" || diffs[0].Key != "uuid" || diffs[0].A != nil || *diffs[0].B != "abc" {
+		t.Fatalf("Missing key diff incorrect: %#v", diffs[0])
+	}
+	if diffs[1].Key != "bind_address" || *diffs[1].A != "0.0.0.0" || *diffs[1].B != "127.0.0.1" {
+		t.Fatalf("Changed value diff incorrect: %#v", diffs[1])
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	ts, couchdb := newTestingServer(200, `{"couchdb":"Welcome","version":"2.1.0"}`)
+	defer ts.Close()
+	path, err := couchdb.configPath("httpd", "port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "_node/_local/_config/httpd/port" {
+		t.Fatalf("Expected: %s, Actual: %s", "_node/_local/_config/httpd/port", path)
+	}
+}
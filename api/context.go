@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"io"
+)
+
+// WithContext returns a copy of the client whose requests are made with ctx,
+// so they are aborted when it is cancelled (including waits between retries).
+// Requests of a client without a context use context.Background.
+func (c *Couchdb) WithContext(ctx context.Context) *Couchdb {
+	withCtx := *c
+	withCtx.ctx = ctx
+	return &withCtx
+}
+
+func (c *Couchdb) requestContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// The following are the variants of each method taking a context.
+
+func (c *Couchdb) GetActiveTasksContext(ctx context.Context) (ActiveTasks, error) {
+	return c.WithContext(ctx).GetActiveTasks()
+}
+
+func (c *Couchdb) GetAttachmentsContext(ctx context.Context, db Database, docId string) (Attachments, error) {
+	return c.WithContext(ctx).GetAttachments(db, docId)
+}
+
+func (c *Couchdb) GetAllAttachmentsContext(ctx context.Context, db Database) (Attachments, error) {
+	return c.WithContext(ctx).GetAllAttachments(db)
+}
+
+func (c *Couchdb) GetAttachmentContext(ctx context.Context, db Database, docId, name string) (io.ReadCloser, error) {
+	return c.WithContext(ctx).GetAttachment(db, docId, name)
+}
+
+func (c *Couchdb) PutAttachmentContext(ctx context.Context, db Database, docId, name, contentType string, body io.Reader, size int64) error {
+	return c.WithContext(ctx).PutAttachment(db, docId, name, contentType, body, size)
+}
+
+func (c *Couchdb) GetConfigContext(ctx context.Context) (Config, error) {
+	return c.WithContext(ctx).GetConfig()
+}
+
+func (c *Couchdb) GetConfigSectionContext(ctx context.Context, section string) (Config, error) {
+	return c.WithContext(ctx).GetConfigSection(section)
+}
+
+func (c *Couchdb) GetConfigValueContext(ctx context.Context, section, key string) (string, error) {
+	return c.WithContext(ctx).GetConfigValue(section, key)
+}
+
+func (c *Couchdb) SetConfigValueContext(ctx context.Context, section, key, value string) (string, error) {
+	return c.WithContext(ctx).SetConfigValue(section, key, value)
+}
+
+func (c *Couchdb) DeleteConfigValueContext(ctx context.Context, section, key string) (string, error) {
+	return c.WithContext(ctx).DeleteConfigValue(section, key)
+}
+
+func (c *Couchdb) GetDatabasesContext(ctx context.Context) (Databases, error) {
+	return c.WithContext(ctx).GetDatabases()
+}
+
+func (c *Couchdb) GetDatabaseInfoContext(ctx context.Context, db Database) (DatabaseInfo, error) {
+	return c.WithContext(ctx).GetDatabaseInfo(db)
+}
+
+func (c *Couchdb) GetViewsContext(ctx context.Context, db Database) (Views, error) {
+	return c.WithContext(ctx).GetViews(db)
+}
+
+func (c *Couchdb) RefreshViewContext(ctx context.Context, view View) error {
+	return c.WithContext(ctx).RefreshView(view)
+}
+
+func (c *Couchdb) RefreshViewsContext(ctx context.Context, views Views) (Views, []error) {
+	return c.WithContext(ctx).RefreshViews(views)
+}
+
+func (c *Couchdb) GetReplicatorsContext(ctx context.Context) (*Replicators, error) {
+	return c.WithContext(ctx).GetReplicators()
+}
+
+func (c *Couchdb) GetReplicatorContext(ctx context.Context, id string) (*Replicator, error) {
+	return c.WithContext(ctx).GetReplicator(id)
+}
+
+func (c *Couchdb) ReplicateContext(ctx context.Context, conf ReplicationConfig) error {
+	return c.WithContext(ctx).Replicate(conf)
+}
+
+func (c *Couchdb) ReplicateTransientContext(ctx context.Context, conf ReplicationConfig) (TransientReplication, error) {
+	return c.WithContext(ctx).ReplicateTransient(conf)
+}
+
+func (c *Couchdb) CancelTransientReplicationContext(ctx context.Context, replicationId string) error {
+	return c.WithContext(ctx).CancelTransientReplication(replicationId)
+}
+
+func (c *Couchdb) ReplicateHostContext(ctx context.Context, remoteURL string, conf ReplicationConfig, opts ReplicateHostOptions) (HostReplicationOutcomes, error) {
+	return c.WithContext(ctx).ReplicateHost(remoteURL, conf, opts)
+}
+
+func (c *Couchdb) DeleteReplicatorContext(ctx context.Context, id string) error {
+	return c.WithContext(ctx).DeleteReplicator(id)
+}
+
+func (c *Couchdb) DeleteAllReplicatorsContext(ctx context.Context) (*Replicators, error) {
+	return c.WithContext(ctx).DeleteAllReplicators()
+}
+
+func (c *Couchdb) PlanIdMigrationContext(ctx context.Context, strategy IdStrategy) (ReplicationPlan, error) {
+	return c.WithContext(ctx).PlanIdMigration(strategy)
+}
+
+func (c *Couchdb) RestartReplicatorContext(ctx context.Context, id string, sinceCheckpoint bool) error {
+	return c.WithContext(ctx).RestartReplicator(id, sinceCheckpoint)
+}
+
+func (c *Couchdb) PauseReplicatorContext(ctx context.Context, id string, sinceCheckpoint bool) error {
+	return c.WithContext(ctx).PauseReplicator(id, sinceCheckpoint)
+}
+
+func (c *Couchdb) ResumeReplicatorContext(ctx context.Context, id string) error {
+	return c.WithContext(ctx).ResumeReplicator(id)
+}
+
+func (c *Couchdb) GetSecurityContext(ctx context.Context, db Database) (Security, error) {
+	return c.WithContext(ctx).GetSecurity(db)
+}
+
+func (c *Couchdb) SetSecurityContext(ctx context.Context, security Security) error {
+	return c.WithContext(ctx).SetSecurity(security)
+}
+
+func (c *Couchdb) AuditSecurityContext(ctx context.Context, filter DatabaseFilter) (Securities, error) {
+	return c.WithContext(ctx).AuditSecurity(filter)
+}
+
+func (c *Couchdb) GetServerContext(ctx context.Context) (Server, error) {
+	return c.WithContext(ctx).GetServer()
+}
+
+func (c *Couchdb) GetUpContext(ctx context.Context) (Up, error) {
+	return c.WithContext(ctx).GetUp()
+}
+
+func (c *Couchdb) GetSessionContext(ctx context.Context) (Session, error) {
+	return c.WithContext(ctx).GetSession()
+}
+
+func (c *Couchdb) GetStatsContext(ctx context.Context, sectionA, sectionB string) (Stats, error) {
+	return c.WithContext(ctx).GetStats(sectionA, sectionB)
+}
+
+func (c *Couchdb) PlanTopologyContext(ctx context.Context, topology Topology) (ReplicationPlan, error) {
+	return c.WithContext(ctx).PlanTopology(topology)
+}
+
+func (c *Couchdb) ApplyPlanContext(ctx context.Context, plan ReplicationPlan, userCtx UserCtx) (ReplicationPlan, error) {
+	return c.WithContext(ctx).ApplyPlan(plan, userCtx)
+}
+
+func (c *Couchdb) GetUsersContext(ctx context.Context) (Users, error) {
+	return c.WithContext(ctx).GetUsers()
+}
+
+func (c *Couchdb) GetUserContext(ctx context.Context, name string) (User, error) {
+	return c.WithContext(ctx).GetUser(name)
+}
+
+func (c *Couchdb) SaveUserContext(ctx context.Context, user User) error {
+	return c.WithContext(ctx).SaveUser(user)
+}
+
+func (c *Couchdb) DeleteUserContext(ctx context.Context, name string) error {
+	return c.WithContext(ctx).DeleteUser(name)
+}
+
+func (c *Couchdb) SetPasswordContext(ctx context.Context, name, password string) error {
+	return c.WithContext(ctx).SetPassword(name, password)
+}
+
+func (c *Couchdb) SetRolesContext(ctx context.Context, name string, roles []string) error {
+	return c.WithContext(ctx).SetRoles(name, roles)
+}
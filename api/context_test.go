@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestContextCancelsRequest(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+	couchdb, _ := New(ts.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := couchdb.GetServerContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, Actual: %v", err)
+	}
+}
+
+func TestContextCancelsRetries(t *testing.T) {
+	ts, attempts, _ := newFlakyServer(10, 503)
+	defer ts.Close()
+	couchdb, _ := New(ts.URL)
+	couchdb.Retry = RetryPolicy{MaxAttempts: 10, Backoff: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := couchdb.GetServerContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, Actual: %v", err)
+	}
+	if *attempts != 1 {
+		t.Fatalf("Expected 1 attempt, Actual: %d", *attempts)
+	}
+}
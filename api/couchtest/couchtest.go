@@ -0,0 +1,431 @@
+// Package couchtest provides fake CouchDB servers for testing without a real server:
+// an in-memory Server implementing the common endpoints, and a ReplayServer
+// serving recorded responses (eg: from a HAR file written with --record).
+package couchtest
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type document map[string]interface{}
+
+type database struct {
+	docs      map[string]document
+	local     map[string]document
+	security  json.RawMessage
+	updateSeq int
+}
+
+func newDatabase() *database {
+	return &database{docs: make(map[string]document), local: make(map[string]document), security: json.RawMessage("{}")}
+}
+
+type failure struct {
+	status int
+	error  string
+	reason string
+}
+
+// Server is an in-memory CouchDB (1.x) with databases, documents with revisions,
+// _all_docs, _security, _replicator, _users, _active_tasks, _stats and _session.
+// Views return no rows and replications aren't run, _replicator docs are only stored.
+// Unsupported endpoints respond 404.
+type Server struct {
+	*httptest.Server
+	mu          sync.Mutex
+	dbs         map[string]*database
+	version     string
+	userCtx     json.RawMessage
+	activeTasks json.RawMessage
+	stats       json.RawMessage
+	failures    map[string]failure
+	requests    []string
+}
+
+// NewServer starts a server holding the _replicator and _users databases
+func NewServer() *Server {
+	s := &Server{
+		dbs:         map[string]*database{"_replicator": newDatabase(), "_users": newDatabase()},
+		version:     "1.6.1",
+		userCtx:     json.RawMessage(`{"name":"admin","roles":["_admin"]}`),
+		activeTasks: json.RawMessage("[]"),
+		stats:       json.RawMessage("{}"),
+		failures:    make(map[string]failure),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// SetVersion sets the version returned by GET /
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// SetSession sets the user of GET /_session
+func (s *Server) SetSession(name string, roles ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userCtx, _ = json.Marshal(map[string]interface{}{"name": name, "roles": append([]string{}, roles...)})
+}
+
+// SetActiveTasks sets the JSON array returned by GET /_active_tasks
+func (s *Server) SetActiveTasks(tasks string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activeTasks = json.RawMessage(tasks)
+}
+
+// SetStats sets the JSON object (section → subsection → stat) returned by GET /_stats
+func (s *Server) SetStats(stats string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = json.RawMessage(stats)
+}
+
+// Fail makes requests of the method and path (eg: "PUT", "/_replicator/rep1") fail with the status
+func (s *Server) Fail(method, path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method+" "+path] = failure{status, strings.ToLower(strings.Replace(http.StatusText(status), " ", "_", -1)), "couchtest failure"}
+}
+
+// CreateDatabase creates the database if it doesn't exist
+func (s *Server) CreateDatabase(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dbs[name] == nil {
+		s.dbs[name] = newDatabase()
+	}
+}
+
+// PutDoc creates or replaces a document, ignoring its _rev, returning the new rev.
+// The database is created if needed.
+func (s *Server) PutDoc(db string, doc string) (string, error) {
+	var d document
+	if err := json.Unmarshal([]byte(doc), &d); err != nil {
+		return "", err
+	}
+	id, _ := d["_id"].(string)
+	if id == "" {
+		return "", fmt.Errorf("document has no _id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dbs[db] == nil {
+		s.dbs[db] = newDatabase()
+	}
+	docs := s.dbs[db].docsOf(id)
+	if existing, found := docs[id]; found {
+		d["_rev"] = existing["_rev"]
+	} else {
+		delete(d, "_rev")
+	}
+	return s.dbs[db].save(docs, id, d), nil
+}
+
+// Doc returns a document as JSON
+func (s *Server) Doc(db, id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dbs[db] == nil {
+		return "", false
+	}
+	doc, found := s.dbs[db].docsOf(id)[id]
+	if !found {
+		return "", false
+	}
+	data, _ := json.Marshal(doc)
+	return string(data), true
+}
+
+// Requests returns the requests received, as "METHOD /path?query"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (d *database) docsOf(id string) map[string]document {
+	if strings.HasPrefix(id, "_local/") {
+		return d.local
+	}
+	return d.docs
+}
+
+// save stores the document with the next revision
+func (d *database) save(docs map[string]document, id string, doc document) string {
+	generation := 0
+	if rev, ok := doc["_rev"].(string); ok {
+		generation, _ = strconv.Atoi(strings.SplitN(rev, "-", 2)[0])
+	}
+	delete(doc, "_rev")
+	doc["_id"] = id
+	data, _ := json.Marshal(doc)
+	rev := fmt.Sprintf("%d-%x", generation+1, md5.Sum(data))
+	doc["_rev"] = rev
+	docs[id] = doc
+	d.updateSeq++
+	return rev
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err, reason string) {
+	writeJson(w, status, map[string]string{"error": err, "reason": reason})
+}
+
+func notFound(w http.ResponseWriter, reason string) {
+	writeError(w, http.StatusNotFound, "not_found", reason)
+}
+
+// splitPath returns the unescaped segments of the path, joining the
+// _design/<name> and _local/<name> document ids
+func splitPath(r *http.Request) []string {
+	var segments []string
+	for _, segment := range strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/") {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if len(segments) > 2 && (segments[1] == "_design" || segments[1] == "_local") {
+		segments = append([]string{segments[0], segments[1] + "/" + segments[2]}, segments[3:]...)
+	}
+	return segments
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	if f, found := s.failures[r.Method+" "+r.URL.Path]; found {
+		writeError(w, f.status, f.error, f.reason)
+		return
+	}
+	segments := splitPath(r)
+	switch {
+	case len(segments) == 0:
+		writeJson(w, 200, map[string]string{"couchdb": "Welcome", "version": s.version})
+	case segments[0] == "_all_dbs":
+		names := make([]string, 0, len(s.dbs))
+		for name := range s.dbs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeJson(w, 200, names)
+	case segments[0] == "_up":
+		writeJson(w, 200, map[string]string{"status": "ok"})
+	case segments[0] == "_session":
+		writeJson(w, 200, map[string]interface{}{"ok": true, "userCtx": s.userCtx})
+	case segments[0] == "_active_tasks":
+		writeJson(w, 200, s.activeTasks)
+	case segments[0] == "_stats":
+		s.serveStats(w, segments[1:])
+	case segments[0] == "_replicate":
+		writeJson(w, 200, map[string]interface{}{"ok": true, "session_id": "couchtest"})
+	case len(segments) == 1:
+		s.serveDatabase(w, r, segments[0])
+	default:
+		db := s.dbs[segments[0]]
+		if db == nil {
+			notFound(w, "no_db_file")
+			return
+		}
+		switch {
+		case segments[1] == "_all_docs":
+			serveAllDocs(w, r, db)
+		case segments[1] == "_security":
+			serveSecurity(w, r, db)
+		case len(segments) == 2:
+			serveDoc(w, r, db, segments[1])
+		case len(segments) == 4 && strings.HasPrefix(segments[1], "_design/") && segments[2] == "_view":
+			writeJson(w, 200, map[string]interface{}{"total_rows": 0, "offset": 0, "rows": []interface{}{}})
+		default:
+			notFound(w, "not implemented by couchtest")
+		}
+	}
+}
+
+func (s *Server) serveStats(w http.ResponseWriter, sections []string) {
+	if len(sections) == 0 {
+		writeJson(w, 200, s.stats)
+		return
+	}
+	var stats map[string]map[string]json.RawMessage
+	json.Unmarshal(s.stats, &stats)
+	if len(sections) != 2 {
+		writeError(w, 400, "bad_request", "stats require a section and subsection")
+		return
+	}
+	stat, found := stats[sections[0]][sections[1]]
+	if !found {
+		writeJson(w, 200, map[string]interface{}{})
+		return
+	}
+	writeJson(w, 200, map[string]map[string]json.RawMessage{sections[0]: {sections[1]: stat}})
+}
+
+func (s *Server) serveDatabase(w http.ResponseWriter, r *http.Request, name string) {
+	db := s.dbs[name]
+	switch r.Method {
+	case "PUT":
+		if db != nil {
+			writeError(w, 412, "file_exists", "The database could not be created, the file already exists.")
+			return
+		}
+		s.dbs[name] = newDatabase()
+		writeJson(w, 201, map[string]bool{"ok": true})
+	case "DELETE":
+		if db == nil {
+			notFound(w, "missing")
+			return
+		}
+		delete(s.dbs, name)
+		writeJson(w, 200, map[string]bool{"ok": true})
+	case "POST":
+		if db == nil {
+			notFound(w, "no_db_file")
+			return
+		}
+		var doc document
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			writeError(w, 400, "bad_request", "invalid json")
+			return
+		}
+		id, _ := doc["_id"].(string)
+		if id == "" {
+			id = fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprint(name, db.updateSeq))))
+		}
+		putDoc(w, db, id, doc, "")
+	default:
+		if db == nil {
+			notFound(w, "no_db_file")
+			return
+		}
+		var size int
+		for _, doc := range db.docs {
+			data, _ := json.Marshal(doc)
+			size += len(data)
+		}
+		writeJson(w, 200, map[string]interface{}{
+			"db_name":       name,
+			"doc_count":     len(db.docs),
+			"doc_del_count": 0,
+			"update_seq":    db.updateSeq,
+			"disk_size":     size,
+			"data_size":     size,
+			"sizes":         map[string]int{"file": size, "active": size, "external": size},
+		})
+	}
+}
+
+func serveAllDocs(w http.ResponseWriter, r *http.Request, db *database) {
+	query := r.URL.Query()
+	var startKey, endKey string
+	json.Unmarshal([]byte(query.Get("startkey")), &startKey)
+	json.Unmarshal([]byte(query.Get("endkey")), &endKey)
+	includeDocs := query.Get("include_docs") == "true"
+	ids := make([]string, 0, len(db.docs))
+	for id := range db.docs {
+		if id >= startKey && (endKey == "" || id <= endKey) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit < len(ids) {
+		ids = ids[:limit]
+	}
+	rows := make([]map[string]interface{}, len(ids))
+	for i, id := range ids {
+		rows[i] = map[string]interface{}{"id": id, "key": id, "value": map[string]interface{}{"rev": db.docs[id]["_rev"]}}
+		if includeDocs {
+			rows[i]["doc"] = db.docs[id]
+		}
+	}
+	writeJson(w, 200, map[string]interface{}{"total_rows": len(db.docs), "offset": 0, "rows": rows})
+}
+
+func serveSecurity(w http.ResponseWriter, r *http.Request, db *database) {
+	if r.Method == "PUT" {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil || !json.Valid(body) {
+			writeError(w, 400, "bad_request", "invalid json")
+			return
+		}
+		db.security = body
+		writeJson(w, 200, map[string]bool{"ok": true})
+		return
+	}
+	writeJson(w, 200, db.security)
+}
+
+func serveDoc(w http.ResponseWriter, r *http.Request, db *database, id string) {
+	docs := db.docsOf(id)
+	existing, found := docs[id]
+	switch r.Method {
+	case "GET", "HEAD":
+		if !found {
+			notFound(w, "missing")
+			return
+		}
+		writeJson(w, 200, existing)
+	case "PUT":
+		var doc document
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			writeError(w, 400, "bad_request", "invalid json")
+			return
+		}
+		putDoc(w, db, id, doc, r.URL.Query().Get("rev"))
+	case "DELETE":
+		if !found {
+			notFound(w, "missing")
+			return
+		}
+		if r.URL.Query().Get("rev") != existing["_rev"] {
+			writeError(w, 409, "conflict", "Document update conflict.")
+			return
+		}
+		delete(docs, id)
+		db.updateSeq++
+		writeJson(w, 200, map[string]interface{}{"ok": true, "id": id, "rev": existing["_rev"]})
+	default:
+		writeError(w, 405, "method_not_allowed", "Only GET,HEAD,PUT,DELETE allowed")
+	}
+}
+
+// putDoc saves the document if its rev (from the body or the rev parameter) is the current one
+func putDoc(w http.ResponseWriter, db *database, id string, doc document, rev string) {
+	if bodyRev, ok := doc["_rev"].(string); ok {
+		rev = bodyRev
+	}
+	docs := db.docsOf(id)
+	var currentRev string
+	if existing, found := docs[id]; found {
+		currentRev, _ = existing["_rev"].(string)
+	}
+	if rev != currentRev {
+		writeError(w, 409, "conflict", "Document update conflict.")
+		return
+	}
+	doc["_rev"] = rev
+	newRev := db.save(docs, id, doc)
+	writeJson(w, 201, map[string]interface{}{"ok": true, "id": id, "rev": newRev})
+}
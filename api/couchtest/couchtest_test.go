@@ -0,0 +1,254 @@
+package couchtest
+
+import (
+	"github.com/awilliams/couchdb-utils/api"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServerDocs(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	rev, err := s.PutDoc("db", `{"_id":"doc","a":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(rev, "1-") {
+		t.Fatalf("Expected first revision, Actual: %s", rev)
+	}
+	if rev, _ = s.PutDoc("db", `{"_id":"doc","a":2}`); !strings.HasPrefix(rev, "2-") {
+		t.Fatalf("Expected second revision, Actual: %s", rev)
+	}
+	doc, found := s.Doc("db", "doc")
+	if !found || !strings.Contains(doc, `"a":2`) {
+		t.Fatalf("Doc incorrect: %s", doc)
+	}
+
+	couchdb, _ := api.New(s.URL)
+	databases, err := couchdb.GetDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(databases) != 3 || databases[0].String() != "_replicator" || databases[2].String() != "db" {
+		t.Fatalf("Databases incorrect: %v", databases)
+	}
+	info, err := couchdb.GetDatabaseInfo(databases[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.DocCount != 1 {
+		t.Fatalf("Expected 1 doc, Actual: %d", info.DocCount)
+	}
+}
+
+func TestServerReplicators(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	couchdb, _ := api.New(s.URL)
+	conf := api.ReplicationConfig{ID: "rep1", Source: api.ReplicationEndpoint{URL: "http://remote/a"}, Target: api.ReplicationEndpoint{URL: "a"}}
+	if err := couchdb.Replicate(conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := couchdb.Replicate(conf); err == nil || !strings.Contains(err.Error(), "conflict") {
+		t.Fatalf("Expected conflict saving without rev, Actual: %v", err)
+	}
+	replicator, err := couchdb.GetReplicator("rep1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicator.Source.URL != "http://remote/a" || replicator.REV == "" {
+		t.Fatalf("Replicator incorrect: %#v", replicator)
+	}
+	if err = couchdb.DeleteReplicator("rep1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := s.Doc("_replicator", "rep1"); found {
+		t.Fatal("Expected replicator to be deleted")
+	}
+
+	s.Fail("PUT", "/_replicator/rep2", 403)
+	conf.ID = "rep2"
+	if err = couchdb.Replicate(conf); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Expected forbidden error, Actual: %v", err)
+	}
+}
+
+func TestServerTasksAndStats(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetActiveTasks(`[{"type":"indexer","database":"db","progress":40}]`)
+	s.SetStats(`{"couchdb":{"open_databases":{"current":3},"request_time":{"mean":1.5}}}`)
+	s.SetSession("bob", "reader")
+	couchdb, _ := api.New(s.URL)
+	tasks, err := couchdb.GetActiveTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Progress != 40 {
+		t.Fatalf("Tasks incorrect: %#v", tasks)
+	}
+	stats, err := couchdb.GetStats("couchdb", "open_databases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Current != 3 {
+		t.Fatalf("Stats incorrect: %#v", stats)
+	}
+	session, err := couchdb.GetSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.UserCtx.Name != "bob" || !reflect.DeepEqual(session.UserCtx.Roles, []string{"reader"}) {
+		t.Fatalf("Session incorrect: %#v", session)
+	}
+}
+
+func TestReplayServer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.CreateDatabase("a")
+	dir, err := ioutil.TempDir("", "couchtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	harPath := filepath.Join(dir, "requests.har")
+
+	// record
+	couchdb, _ := api.New(s.URL)
+	har, err := api.CreateHAR(harPath, "couchtest", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	couchdb.Transport = &api.Tracer{Handler: func(e *api.Exchange) {
+		if err := har.Add(e); err != nil {
+			t.Error(err)
+		}
+	}}
+	recorded, err := couchdb.GetDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = couchdb.GetServer(); err != nil {
+		t.Fatal(err)
+	}
+	if err = har.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// replay
+	fixtures, err := LoadHAR(harPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := NewReplayServer(fixtures)
+	defer replay.Close()
+	couchdb, _ = api.New(replay.URL)
+	replayed, err := couchdb.GetDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recorded, replayed) {
+		t.Fatalf("Expected: %v, Actual: %v", recorded, replayed)
+	}
+	server, err := couchdb.GetServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Version != "1.6.1" {
+		t.Fatalf("Expected version 1.6.1, Actual: %s", server.Version)
+	}
+	if _, err = couchdb.GetSession(); err == nil {
+		t.Fatal("Expected error for request without fixture")
+	}
+	if unmatched := replay.Unmatched(); len(unmatched) != 1 || unmatched[0] != "GET /_session" {
+		t.Fatalf("Unmatched incorrect: %v", unmatched)
+	}
+}
+
+func TestServerDatabaseNames(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	couchdb, _ := api.New(s.URL)
+	for _, name := range []string{"team/orders", "a+b", "a$b", "a(b)", "a_b-c", "a0/9$(+)_-"} {
+		if _, err := s.PutDoc(name, `{"_id":"_design/app","views":{"v":{"map":"function(doc){}"}}}`); err != nil {
+			t.Fatal(err)
+		}
+		db := api.Database{Name: &name}
+		info, err := couchdb.GetDatabaseInfo(db)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if info.DocCount != 1 {
+			t.Fatalf("%s: Expected 1 doc, Actual: %d", name, info.DocCount)
+		}
+		views, err := couchdb.GetViews(db)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(views) != 1 {
+			t.Fatalf("%s: Expected 1 design doc, Actual: %v", name, views)
+		}
+		if _, errs := couchdb.RefreshViews(views); len(errs) != 0 {
+			t.Fatalf("%s: %v", name, errs)
+		}
+		security := api.Security{Database: db}
+		if err = couchdb.SetSecurity(security); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err = couchdb.GetSecurity(db); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestReplayBinary(t *testing.T) {
+	png := "\x89PNG\x00\x01"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, png)
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "couchtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	harPath := filepath.Join(dir, "requests.har")
+	har, err := api.CreateHAR(harPath, "couchtest", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer har.Close()
+	couchdb, _ := api.New(ts.URL)
+	couchdb.Transport = &api.Tracer{Handler: func(e *api.Exchange) { har.Add(e) }}
+	db := "db"
+	reader, err := couchdb.GetAttachment(api.Database{Name: &db}, "doc", "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(reader)
+	reader.Close()
+
+	fixtures, err := LoadHAR(harPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := NewReplayServer(fixtures)
+	defer replay.Close()
+	couchdb, _ = api.New(replay.URL)
+	if reader, err = couchdb.GetAttachment(api.Database{Name: &db}, "doc", "a.png"); err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if body, _ := ioutil.ReadAll(reader); string(body) != png {
+		t.Fatalf("Expected: %q, Actual: %q", png, body)
+	}
+}
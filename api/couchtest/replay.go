@@ -0,0 +1,130 @@
+package couchtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+)
+
+// Fixture is a recorded response to a request. Path includes the query string.
+type Fixture struct {
+	Method      string
+	Path        string
+	Status      int
+	ContentType string
+	Body        string
+}
+
+func (f Fixture) key() string {
+	return f.Method + " " + f.Path
+}
+
+// ReplayServer serves fixtures to the requests matching their method and path.
+// Fixtures of the same request are served in order, the last one being repeated.
+// Requests without a fixture respond 500 and are listed by Unmatched.
+type ReplayServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	fixtures  map[string][]Fixture
+	unmatched []string
+}
+
+func NewReplayServer(fixtures []Fixture) *ReplayServer {
+	s := &ReplayServer{fixtures: make(map[string][]Fixture)}
+	for _, fixture := range fixtures {
+		s.fixtures[fixture.key()] = append(s.fixtures[fixture.key()], fixture)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Unmatched returns the requests which had no fixture, as "METHOD /path?query"
+func (s *ReplayServer) Unmatched() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.unmatched...)
+}
+
+func (s *ReplayServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := r.Method + " " + r.URL.RequestURI()
+	fixtures := s.fixtures[key]
+	if len(fixtures) == 0 {
+		s.unmatched = append(s.unmatched, key)
+		writeError(w, 500, "couchtest", "no fixture for "+key)
+		return
+	}
+	fixture := fixtures[0]
+	if len(fixtures) > 1 {
+		s.fixtures[key] = fixtures[1:]
+	}
+	if fixture.ContentType != "" {
+		w.Header().Set("Content-Type", fixture.ContentType)
+	}
+	w.WriteHeader(fixture.Status)
+	w.Write([]byte(fixture.Body))
+}
+
+// har holds the fields of a HAR archive needed to replay it
+type har struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method string `json:"method"`
+				URL    string `json:"url"`
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Content struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// LoadHAR reads the fixtures of a HAR archive, such as those written with --record.
+// Entries without a response (failed requests) are skipped.
+func LoadHAR(path string) ([]Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var archive har
+	if err = json.Unmarshal(data, &archive); err != nil {
+		return nil, err
+	}
+	var fixtures []Fixture
+	for _, entry := range archive.Log.Entries {
+		if entry.Response.Status == 0 {
+			continue
+		}
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		body := entry.Response.Content.Text
+		if entry.Response.Content.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(body)
+			if err != nil {
+				return nil, err
+			}
+			body = string(decoded)
+		}
+		fixtures = append(fixtures, Fixture{
+			Method:      entry.Request.Method,
+			Path:        u.RequestURI(),
+			Status:      entry.Response.Status,
+			ContentType: entry.Response.Content.MimeType,
+			Body:        body,
+		})
+	}
+	return fixtures, nil
+}
@@ -2,6 +2,8 @@ package api
 
 import (
 	"github.com/awilliams/couchdb-utils/util"
+	"regexp"
+	"strings"
 )
 
 type Database struct {
@@ -26,18 +28,118 @@ func (d *Database) UnmarshalJSON(data []byte) error {
 
 type Databases []Database
 
+// IsSystemDatabase returns true for the databases reserved by couchdb, which
+// begin with '_', eg: _users and _replicator
+func IsSystemDatabase(name string) bool {
+	return strings.HasPrefix(name, "_")
+}
+
+// DatabaseFilter matches database names against optional include and exclude patterns.
+// A name matches if it matches Include (or Include is nil) and doesn't match Exclude.
+// System databases only match with IncludeSystem, so the zero value skips them.
+type DatabaseFilter struct {
+	Include       *regexp.Regexp
+	Exclude       *regexp.Regexp
+	IncludeSystem bool
+}
+
+func (f DatabaseFilter) Match(name string) bool {
+	if !f.IncludeSystem && IsSystemDatabase(name) {
+		return false
+	}
+	if f.Include != nil && !f.Include.MatchString(name) {
+		return false
+	}
+	return f.Exclude == nil || !f.Exclude.MatchString(name)
+}
+
+// CompileGlob compiles a shell pattern matching whole database names, where
+// '*' matches any characters (including '/') and '?' a single one, eg: orders_*
+func CompileGlob(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
 func (d Databases) PP(printer util.Printer) {
 	for _, db := range d {
 		db.PP(printer)
 	}
 }
 
+// Filter returns the databases matching the filter
+func (d Databases) Filter(filter DatabaseFilter) Databases {
+	var matching Databases
+	for _, db := range d {
+		if filter.Match(*db.Name) {
+			matching = append(matching, db)
+		}
+	}
+	return matching
+}
+
 func (d *Databases) path() string {
 	return "_all_dbs"
 }
 
-func (c Couchdb) GetDatabases() (Databases, error) {
+func (c *Couchdb) GetDatabases() (Databases, error) {
 	databases := new(Databases)
 	err := c.getJson(databases, databases.path())
 	return *databases, err
 }
+
+type DatabaseInfo struct {
+	DbName            string      `json:"db_name"`
+	DocCount          int64       `json:"doc_count"`
+	DocDelCount       int64       `json:"doc_del_count"`
+	UpdateSeq         interface{} `json:"update_seq"` // integer in 1.x, string in 2.x
+	DiskSize          int64       `json:"disk_size"`  // 1.x
+	DataSize          int64       `json:"data_size"`  // 1.x
+	CompactRunning    bool        `json:"compact_running"`
+	InstanceStartTime string      `json:"instance_start_time"`
+	Sizes             struct {    // 2.x
+		File     int64 `json:"file"`
+		Active   int64 `json:"active"`
+		External int64 `json:"external"`
+	} `json:"sizes"`
+}
+
+// FileSize returns the size of the database file on disk
+func (d DatabaseInfo) FileSize() int64 {
+	if d.Sizes.File != 0 {
+		return d.Sizes.File
+	}
+	return d.DiskSize
+}
+
+// ActiveSize returns the size of the live data in the database
+func (d DatabaseInfo) ActiveSize() int64 {
+	if d.Sizes.Active != 0 {
+		return d.Sizes.Active
+	}
+	return d.DataSize
+}
+
+func (d DatabaseInfo) PP(printer util.Printer) {
+	printer.Print("[%s]", d.DbName)
+	printer.Print(" Docs: %d (%d deleted)", d.DocCount, d.DocDelCount)
+	printer.Print(" Size: %d (%d active)", d.FileSize(), d.ActiveSize())
+	printer.Print(" Compacting: %v", d.CompactRunning)
+}
+
+func (c *Couchdb) GetDatabaseInfo(db Database) (DatabaseInfo, error) {
+	info := new(DatabaseInfo)
+	err := c.getJson(info, db.path())
+	return *info, err
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestDatabaseFilter(t *testing.T) {
+	filter := DatabaseFilter{Include: regexp.MustCompile("^orders"), Exclude: regexp.MustCompile("_test$")}
+	cases := map[string]bool{
+		"orders":      true,
+		"orders_eu":   true,
+		"orders_test": false,
+		"users":       false,
+	}
+	for name, expected := range cases {
+		if filter.Match(name) != expected {
+			t.Fatalf("Match(%s) incorrect. Expected: %v", name, expected)
+		}
+	}
+	if (DatabaseFilter{}).Match("_users") || !(DatabaseFilter{}).Match("users") {
+		t.Fatal("Empty filter should match everything but system databases")
+	}
+	if !(DatabaseFilter{IncludeSystem: true}).Match("_users") {
+		t.Fatal("IncludeSystem should match system databases")
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	cases := map[string]map[string]bool{
+		"orders_*": {"orders_eu": true, "orders_": true, "orders": false, "my_orders_eu": false, "orders_eu/2020": true},
+		"a?c":      {"abc": true, "ac": false, "abbc": false},
+		"a+b$(c)":  {"a+b$(c)": true, "aab$c": false},
+	}
+	for pattern, names := range cases {
+		glob, err := CompileGlob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, expected := range names {
+			if glob.MatchString(name) != expected {
+				t.Fatalf("%s matching %s incorrect. Expected: %v", pattern, name, expected)
+			}
+		}
+	}
+}
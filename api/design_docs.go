@@ -27,7 +27,7 @@ func (v View) String() string {
 }
 
 func (v View) refreshPath() string {
-	return fmt.Sprintf(`%s/%s/_view/%s?limit=0&stale=update_after`, v.Database.String(), v.DesignDoc.ID, v.Name)
+	return fmt.Sprintf(`%s/%s/_view/%s?limit=0&stale=update_after`, v.Database.path(), escapeDocId(v.DesignDoc.ID), escapeSegment(v.Name))
 }
 
 func (v View) PP(printer util.Printer) {
@@ -58,7 +58,7 @@ func (d DesignDoc) PP(printer util.Printer) {
 type Views map[DesignDoc][]View
 
 func (v Views) path(db Database) string {
-	return fmt.Sprintf(`%s/_all_docs?startkey="_design/"&endkey="_design0"&include_docs=true`, db.String())
+	return fmt.Sprintf(`%s/_all_docs?startkey=%s&endkey=%s&include_docs=true`, db.path(), jsonKey("_design/"), jsonKey("_design0"))
 }
 
 func (v Views) PP(printer util.Printer) {
@@ -69,7 +69,7 @@ func (v Views) PP(printer util.Printer) {
 	}
 }
 
-func (c Couchdb) GetViews(db Database) (Views, error) {
+func (c *Couchdb) GetViews(db Database) (Views, error) {
 	j := new(viewsJson)
 	views := make(Views)
 	err := c.getJson(j, views.path(db))
@@ -85,8 +85,8 @@ func (c Couchdb) GetViews(db Database) (Views, error) {
 	return views, nil
 }
 
-func (c Couchdb) RefreshView(view View) error {
-	reader, err := c.get(view.refreshPath())
+func (c *Couchdb) RefreshView(view View) error {
+	reader, err := c.trigger(view.refreshPath())
 	if err != nil {
 		return err
 	}
@@ -94,10 +94,11 @@ func (c Couchdb) RefreshView(view View) error {
 	return nil
 }
 
-func (c Couchdb) RefreshViews(views Views) (Views, []error) {
+func (c *Couchdb) RefreshViews(views Views) (Views, []error) {
 	refreshedViews := make(Views)
 	var errors []error
 	var w sync.WaitGroup
+	var mu sync.Mutex
 	for designDoc, viewArr := range views {
 		w.Add(1)
 		var view View = viewArr[0]
@@ -105,7 +106,9 @@ func (c Couchdb) RefreshViews(views Views) (Views, []error) {
 		go func(v View) {
 			err := c.RefreshView(v)
 			if err != nil {
+				mu.Lock()
 				errors = append(errors, err)
+				mu.Unlock()
 			}
 			w.Done()
 		}(view)
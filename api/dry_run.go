@@ -0,0 +1,65 @@
+package api
+
+import (
+	"github.com/awilliams/couchdb-utils/util"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Request is a write request which wasn't sent because of a dry run.
+// Body is only kept for JSON requests, with credentials and passwords redacted
+// as in traces. Size is the length of the body.
+type Request struct {
+	Method      string
+	Path        string
+	ContentType string
+	Body        string
+	Size        int64
+}
+
+func (r Request) PP(printer util.Printer) {
+	printer.Print("%s %s", r.Method, r.Path)
+	if r.Body != "" {
+		printer.Print("%s", r.Body)
+	} else if r.Size > 0 {
+		printer.Print("<%d bytes of %s>", r.Size, r.ContentType)
+	}
+}
+
+// dryRun passes the request to the DryRunHandler instead of sending it. The
+// response is a JSON null, which decodes into the zero value of any type.
+func (c *Couchdb) dryRun(method string, bodyType string, body io.Reader, path string) (io.ReadCloser, error) {
+	request := Request{Method: method, Path: sanitizePath(c.url(path)), ContentType: bodyType}
+	if body != nil {
+		if bodyType == JSONCONTENTTYPE {
+			data, err := ioutil.ReadAll(body)
+			if err != nil {
+				return nil, err
+			}
+			request.Body, request.Size = string(redactBody(path, data)), int64(len(data))
+		} else {
+			size, err := io.Copy(ioutil.Discard, body)
+			if err != nil {
+				return nil, err
+			}
+			request.Size = size
+		}
+	}
+	c.DryRunHandler(&request)
+	return ioutil.NopCloser(strings.NewReader("null")), nil
+}
+
+// isWrite returns true for the requests which change the server
+func isWrite(method string) bool {
+	return method != GET && method != HEAD
+}
+
+// trigger is a GET made for its side effect (eg: building an index),
+// so it is treated as a write by dry runs
+func (c *Couchdb) trigger(path string) (io.ReadCloser, error) {
+	if c.DryRunHandler != nil {
+		return c.dryRun(GET, "", nil, path)
+	}
+	return c.get(path)
+}
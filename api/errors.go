@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// CouchdbError is returned for responses with an error status. ErrorMsg and
+// Reason are those of the JSON body, if any, eg: "not_found" and "missing".
+// Use IsNotFound, IsConflict and IsUnauthorized to check errors which may be wrapped.
+type CouchdbError struct {
+	ErrorMsg string `json:"error"`
+	Status   int
+	Reason   string
+}
+
+func (e CouchdbError) Error() string {
+	var err string
+	switch {
+	case e.ErrorMsg != "" && e.Reason != "":
+		err = fmt.Sprintf("%s: %s", e.ErrorMsg, e.Reason)
+	case e.ErrorMsg != "":
+		err = e.ErrorMsg
+	case e.Reason != "":
+		err = e.Reason
+	case e.Status != 0:
+		return fmt.Sprintf("HTTP %d %s", e.Status, http.StatusText(e.Status))
+	default:
+		return "unknown couchdb error"
+	}
+	if e.Status != 0 {
+		err += fmt.Sprintf("\nHTTP %d", e.Status)
+	}
+	return err
+}
+
+func (e CouchdbError) IsConflict() bool {
+	return e.Status == 409
+}
+
+func (e CouchdbError) IsNotFound() bool {
+	return e.Status == 404
+}
+
+// IsUnauthorized is true for missing or wrong credentials (401) and for
+// credentials lacking the permission (403)
+func (e CouchdbError) IsUnauthorized() bool {
+	return e.Status == 401 || e.Status == 403
+}
+
+// AsCouchdbError returns the CouchdbError of err, which may be wrapped
+func AsCouchdbError(err error) (CouchdbError, bool) {
+	var couchErr CouchdbError
+	if errors.As(err, &couchErr) {
+		return couchErr, true
+	}
+	var couchErrPtr *CouchdbError
+	if errors.As(err, &couchErrPtr) && couchErrPtr != nil {
+		return *couchErrPtr, true
+	}
+	return couchErr, false
+}
+
+// IsNotFound returns true if err is a 404 response, eg: a missing document or database
+func IsNotFound(err error) bool {
+	couchErr, ok := AsCouchdbError(err)
+	return ok && couchErr.IsNotFound()
+}
+
+// IsConflict returns true if err is a 409 response, eg: saving a document with an old revision
+func IsConflict(err error) bool {
+	couchErr, ok := AsCouchdbError(err)
+	return ok && couchErr.IsConflict()
+}
+
+// IsUnauthorized returns true if err is a 401 or 403 response
+func IsUnauthorized(err error) bool {
+	couchErr, ok := AsCouchdbError(err)
+	return ok && couchErr.IsUnauthorized()
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCouchdbErrorMessage(t *testing.T) {
+	cases := map[string]CouchdbError{
+		"not_found: missing\nHTTP 404": {ErrorMsg: "not_found", Reason: "missing", Status: 404},
+		"conflict\nHTTP 409":           {ErrorMsg: "conflict", Status: 409},
+		"HTTP 503 Service Unavailable": {Status: 503},
+		"unknown couchdb error":        {},
+	}
+	for expected, err := range cases {
+		if err.Error() != expected {
+			t.Fatalf("Expected: %q, Actual: %q", expected, err.Error())
+		}
+	}
+}
+
+func TestErrorChecks(t *testing.T) {
+	wrapped := fmt.Errorf("deleting: %w", CouchdbError{Status: 404})
+	if !IsNotFound(wrapped) || IsConflict(wrapped) || IsUnauthorized(wrapped) {
+		t.Fatal("Expected wrapped error to be not found only")
+	}
+	if !IsConflict(&CouchdbError{Status: 409}) {
+		t.Fatal("Expected pointer error to be a conflict")
+	}
+	if !IsUnauthorized(CouchdbError{Status: 401}) || !IsUnauthorized(CouchdbError{Status: 403}) {
+		t.Fatal("Expected 401 and 403 to be unauthorized")
+	}
+	if IsNotFound(fmt.Errorf("other")) || IsNotFound(nil) {
+		t.Fatal("Expected other errors not to be not found")
+	}
+}
+
+func TestDeleteReplicatorError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			w.WriteHeader(409)
+			fmt.Fprint(w, `{"error":"conflict","reason":"Document update conflict."}`)
+			return
+		}
+		fmt.Fprint(w, `{"_id":"rep1","_rev":"1-a"}`)
+	}))
+	defer ts.Close()
+	couchdb, _ := New(ts.URL)
+	if err := couchdb.DeleteReplicator("rep1"); !IsConflict(err) {
+		t.Fatalf("Expected conflict, Actual: %v", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	handler := func(*Request) {}
+	couchdb, err := New("localhost:5984", WithIdStrategy(HashIds), WithRetry(RetryPolicy{MaxAttempts: 3}), WithDryRun(handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if couchdb.Retry.MaxAttempts != 3 || couchdb.DryRunHandler == nil || couchdb.IdStrategy == nil {
+		t.Fatalf("Options not applied: %#v", couchdb)
+	}
+	if couchdb.URL() != "http://localhost:5984/" {
+		t.Fatalf("URL incorrect: %s", couchdb.URL())
+	}
+	if _, err = New("localhost:5984", WithRetry(RetryPolicy{Backoff: -1})); err == nil {
+		t.Fatal("Expected error for negative backoff")
+	}
+}
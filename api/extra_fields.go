@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// extraFields holds the fields of a document which its struct doesn't declare,
+// eg: the email of a user, so that they are kept when the document is saved back
+type extraFields map[string]json.RawMessage
+
+// unmarshalWithExtra decodes data into known, a pointer to a struct, returning
+// the fields it doesn't declare
+func unmarshalWithExtra(data []byte, known interface{}) (extraFields, error) {
+	if err := json.Unmarshal(data, known); err != nil {
+		return nil, err
+	}
+	var all extraFields
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for name := range jsonFields(reflect.TypeOf(known).Elem()) {
+		delete(all, name)
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+	return all, nil
+}
+
+// marshalWithExtra encodes the known struct along with the extra fields
+func marshalWithExtra(known interface{}, extra extraFields) ([]byte, error) {
+	data, err := json.Marshal(known)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	var all extraFields
+	if err = json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for name, value := range extra {
+		all[name] = value
+	}
+	return json.Marshal(all)
+}
+
+// jsonFields returns the json names of the fields of a struct type, including
+// those of embedded structs
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+		tag := field.Tag.Get("json")
+		if field.Anonymous && tag == "" {
+			for name := range jsonFields(field.Type) {
+				fields[name] = true
+			}
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
@@ -0,0 +1,253 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// harTimings are in milliseconds, -1 when not applicable
+type harTimings struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// orNotApplicable returns the duration in milliseconds, or -1 for a step which didn't happen
+func orNotApplicable(d time.Duration) float64 {
+	if d == 0 {
+		return -1
+	}
+	return milliseconds(d)
+}
+
+func harHeaders(headers http.Header) []harNameValue {
+	values := []harNameValue{}
+	for name, headerValues := range headers {
+		for _, value := range headerValues {
+			values = append(values, harNameValue{name, value})
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+	return values
+}
+
+func harQueryString(rawurl string) []harNameValue {
+	values := []harNameValue{}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return values
+	}
+	for name, queryValues := range u.Query() {
+		for _, value := range queryValues {
+			values = append(values, harNameValue{name, value})
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+	return values
+}
+
+// bodyText returns the body as text, base64 encoded if it is binary (eg: an attachment)
+func bodyText(headers http.Header, body []byte) (text string, encoding string) {
+	if isText(headers) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func newHarEntry(e *Exchange) harEntry {
+	entry := harEntry{
+		StartedDateTime: e.Started.Format(time.RFC3339Nano),
+		Time:            milliseconds(e.Timings.Total),
+		Request: harRequest{
+			Method:      e.Method,
+			URL:         e.URL,
+			HTTPVersion: e.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(e.RequestHeaders),
+			QueryString: harQueryString(e.URL),
+			HeadersSize: -1,
+			BodySize:    len(e.RequestBody),
+		},
+		Response: harResponse{
+			Status:      e.StatusCode,
+			StatusText:  strings.TrimSpace(strings.TrimPrefix(e.Status, strconv.Itoa(e.StatusCode))),
+			HTTPVersion: e.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(e.ResponseHeaders),
+			Content: harContent{
+				Size:     len(e.ResponseBody),
+				MimeType: e.ResponseHeaders.Get("Content-Type"),
+			},
+			HeadersSize: -1,
+			BodySize:    len(e.ResponseBody),
+		},
+		Timings: harTimings{
+			DNS:     orNotApplicable(e.Timings.DNS),
+			Connect: orNotApplicable(e.Timings.Connect),
+			SSL:     orNotApplicable(e.Timings.TLS),
+			Wait:    milliseconds(e.Timings.TTFB - e.Timings.DNS - e.Timings.Connect - e.Timings.TLS),
+			Receive: milliseconds(e.Timings.Total - e.Timings.TTFB),
+		},
+	}
+	if len(e.ResponseBody) > 0 {
+		entry.Response.Content.Text, entry.Response.Content.Encoding = bodyText(e.ResponseHeaders, e.ResponseBody)
+	}
+	if len(e.RequestBody) > 0 {
+		entry.Request.PostData = &harPostData{MimeType: e.RequestHeaders.Get("Content-Type")}
+		entry.Request.PostData.Text, entry.Request.PostData.Encoding = bodyText(e.RequestHeaders, e.RequestBody)
+	}
+	if e.Error != nil {
+		entry.Comment = e.Error.Error()
+	}
+	return entry
+}
+
+// harTrailer closes the entries and log of a HAR file
+const harTrailer = "\n]}}\n"
+
+// HARWriter writes exchanges to a file in the HTTP Archive 1.2 format, which
+// can be opened by browser developer tools and HAR viewers.
+// http://www.softwareishard.com/blog/har-12-spec/
+// Exchanges are written as they are added, the file is a complete archive
+// after each one as commands may exit at any point.
+type HARWriter struct {
+	mu      sync.Mutex
+	file    *os.File
+	offset  int64 // where the next entry is written, over the trailer
+	entries int
+}
+
+// CreateHAR creates the HAR file, replacing it, with no entries
+func CreateHAR(path, creator, version string) (*HARWriter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(harLog{Version: "1.2", Creator: harCreator{creator, version}})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// the entries are the last field, reopen them: {"log":{...,"entries":[
+	header = append([]byte(`{"log":`), header[:len(header)-len("null}")]...)
+	header = append(header, '[')
+	w := &HARWriter{file: file}
+	if err = w.write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// write writes data at the offset, followed by the trailer
+func (w *HARWriter) write(data []byte) error {
+	if _, err := w.file.WriteAt(append(data, harTrailer...), w.offset); err != nil {
+		return err
+	}
+	w.offset += int64(len(data))
+	return nil
+}
+
+// Add writes the exchange
+func (w *HARWriter) Add(e *Exchange) error {
+	data, err := json.Marshal(newHarEntry(e))
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	separator := "\n"
+	if w.entries > 0 {
+		separator = ",\n"
+	}
+	if err = w.write(append([]byte(separator), data...)); err != nil {
+		return err
+	}
+	w.entries++
+	return nil
+}
+
+// Close closes the file
+func (w *HARWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
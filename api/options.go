@@ -0,0 +1,55 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Option configures a client created with New
+type Option func(*Couchdb) error
+
+// WithResultHandler calls the handler after each request, eg: to log it
+func WithResultHandler(handler func(*Result)) Option {
+	return func(c *Couchdb) error {
+		c.ResultHandler = handler
+		return nil
+	}
+}
+
+// WithIdStrategy sets the id scheme of replicators created without an id
+func WithIdStrategy(strategy IdStrategy) Option {
+	return func(c *Couchdb) error {
+		if strategy == nil {
+			return fmt.Errorf("id strategy is nil")
+		}
+		c.IdStrategy = strategy
+		return nil
+	}
+}
+
+// WithRetry retries failed requests, see RetryPolicy
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Couchdb) error {
+		if policy.Backoff < 0 || policy.MaxBackoff < 0 {
+			return fmt.Errorf("retry backoff can't be negative")
+		}
+		c.Retry = policy
+		return nil
+	}
+}
+
+// WithDryRun passes the write requests to the handler instead of sending them
+func WithDryRun(handler func(*Request)) Option {
+	return func(c *Couchdb) error {
+		c.DryRunHandler = handler
+		return nil
+	}
+}
+
+// WithTransport sends the requests with the transport, eg: a Tracer or one with custom TLS settings
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Couchdb) error {
+		c.Transport = transport
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// escapeSegment escapes everything but unreserved characters, so that the
+// slashes and pluses legal in database names aren't read as path separators
+// or spaces
+func escapeSegment(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+// path returns the escaped database name, eg: team%2Forders
+func (d Database) path() string {
+	return escapeSegment(*d.Name)
+}
+
+// escapeDocId escapes a document id for use in a path, keeping the slash of
+// design and local documents
+func escapeDocId(id string) string {
+	for _, prefix := range []string{"_design/", "_local/"} {
+		if strings.HasPrefix(id, prefix) {
+			return prefix + escapeSegment(strings.TrimPrefix(id, prefix))
+		}
+	}
+	return escapeSegment(id)
+}
+
+// escapeAttachmentName escapes each part of an attachment name, which may contain slashes
+func escapeAttachmentName(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = escapeSegment(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// jsonKey encodes a string key as JSON for use in a query, eg: startkey
+func jsonKey(key string) string {
+	data, _ := json.Marshal(key)
+	return url.QueryEscape(string(data))
+}
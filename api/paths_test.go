@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// legalDbNames covers every character CouchDB allows in a database name
+var legalDbNames = []string{"db", "a0_9", "team/orders", "a+b", "a$b", "a(b)", "a-b", "a_b$c(d)+e/f-g"}
+
+func TestEscapeSegment(t *testing.T) {
+	cases := map[string]string{
+		"team/orders": "team%2Forders",
+		"a+b":         "a%2Bb",
+		"a$b":         "a%24b",
+		"a(b)":        "a%28b%29",
+		"a_b-c":       "a_b-c",
+		"a b":         "a%20b",
+	}
+	for s, expected := range cases {
+		if escaped := escapeSegment(s); escaped != expected {
+			t.Fatalf("escapeSegment(%s) incorrect. Expected: %s, Actual: %s", s, expected, escaped)
+		}
+	}
+	for _, name := range legalDbNames {
+		if unescaped, err := url.PathUnescape(escapeSegment(name)); err != nil || unescaped != name {
+			t.Fatalf("escapeSegment(%s) doesn't round trip: %s %v", name, unescaped, err)
+		}
+	}
+}
+
+func TestDatabasePaths(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		fmt.Fprint(w, `{"db_name":"x","rows":[]}`)
+	}))
+	defer ts.Close()
+	couchdb, _ := New(ts.URL)
+
+	name := "a_b$c(d)+e/f-g"
+	db := Database{Name: &name}
+	if _, err := couchdb.GetDatabaseInfo(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := couchdb.GetViews(db); err != nil {
+		t.Fatal(err)
+	}
+	view := View{Name: "by date", Database: db, DesignDoc: DesignDoc{ID: "_design/my app"}}
+	if err := couchdb.RefreshView(view); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := couchdb.GetAllAttachments(db); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := couchdb.GetAttachment(db, "doc/1", "img/a+b.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Close()
+	if err = couchdb.SaveUser(NewUser("bob/smith", "secret", nil)); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"/a_b%24c%28d%29%2Be%2Ff-g",
+		`/a_b%24c%28d%29%2Be%2Ff-g/_all_docs?startkey=%22_design%2F%22&endkey=%22_design0%22&include_docs=true`,
+		"/a_b%24c%28d%29%2Be%2Ff-g/_design/my%20app/_view/by%20date?limit=0&stale=update_after",
+		"/a_b%24c%28d%29%2Be%2Ff-g/_all_docs?include_docs=true",
+		"/a_b%24c%28d%29%2Be%2Ff-g/doc%2F1/img/a%2Bb.png",
+		"/_users/org.couchdb.user%3Abob%2Fsmith",
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected %d requests, Actual: %v", len(expected), paths)
+	}
+	for i, path := range expected {
+		if paths[i] != path {
+			t.Fatalf("Path incorrect. Expected: %s, Actual: %s", path, paths[i])
+		}
+	}
+}
+
+func TestReplicatorPaths(t *testing.T) {
+	cases := map[string]ReplicationConfig{
+		"_replicator/a%2Fb":             {ID: "a/b"},
+		"_replicator/a%2Bb?rev=1-a%2Bb": {ID: "a+b", REV: "1-a+b"},
+	}
+	for expected, conf := range cases {
+		if path := conf.path(); path != expected {
+			t.Fatalf("Path incorrect. Expected: %s, Actual: %s", expected, path)
+		}
+	}
+	if path := pausedPath("a/b"); path != "_replicator/_local/paused-a%2Fb" {
+		t.Fatalf("Paused path incorrect: %s", path)
+	}
+}
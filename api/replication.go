@@ -2,22 +2,106 @@ package api
 
 import (
 	"bytes"
-	"crypto/md5"
 	"encoding/json"
 	"fmt"
 	"github.com/awilliams/couchdb-utils/util"
 	"io"
+	"net/url"
+	"reflect"
+	"strings"
 )
 
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type EndpointAuth struct {
+	Basic *BasicAuth `json:"basic,omitempty"`
+}
+
+// ReplicationEndpoint is the source or target of a replication. It is encoded
+// as a plain url unless headers or auth are given, in which case the object form is used.
+type ReplicationEndpoint struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Auth    *EndpointAuth     `json:"auth,omitempty"`
+}
+
+func (e ReplicationEndpoint) String() string {
+	return e.URL
+}
+
+func (e ReplicationEndpoint) isPlain() bool {
+	return len(e.Headers) == 0 && e.Auth == nil
+}
+
+func (e ReplicationEndpoint) MarshalJSON() ([]byte, error) {
+	if e.isPlain() {
+		return json.Marshal(e.URL)
+	}
+	type endpoint ReplicationEndpoint // avoid recursion
+	return json.Marshal(endpoint(e))
+}
+
+func (e *ReplicationEndpoint) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &e.URL)
+	}
+	type endpoint ReplicationEndpoint // avoid recursion
+	return json.Unmarshal(data, (*endpoint)(e))
+}
+
 type ReplicationConfig struct {
-	ID           string  `json:"_id,omitempty"`
-	REV          string  `json:"_rev,omitempty"`
-	Source       string  `json:"source"`
-	Target       string  `json:"target"`
-	Cancel       bool    `json:"cancel"`
-	CreateTarget bool    `json:"create_target"`
-	Continuous   bool    `json:"continuous"`
-	UserCtx      UserCtx `json:"user_ctx"` // see api/session
+	ID                 string                 `json:"_id,omitempty"`
+	REV                string                 `json:"_rev,omitempty"`
+	Source             ReplicationEndpoint    `json:"source"`
+	Target             ReplicationEndpoint    `json:"target"`
+	Cancel             bool                   `json:"cancel"`
+	CreateTarget       bool                   `json:"create_target"`
+	Continuous         bool                   `json:"continuous"`
+	Filter             string                 `json:"filter,omitempty"`
+	QueryParams        map[string]interface{} `json:"query_params,omitempty"`
+	Selector           map[string]interface{} `json:"selector,omitempty"`
+	DocIds             []string               `json:"doc_ids,omitempty"`
+	SinceSeq           interface{}            `json:"since_seq,omitempty"` // integer in 1.x, string in 2.x
+	WorkerProcesses    int                    `json:"worker_processes,omitempty"`
+	HttpConnections    int                    `json:"http_connections,omitempty"`
+	CheckpointInterval int                    `json:"checkpoint_interval,omitempty"` // milliseconds
+	UseCheckpoints     *bool                  `json:"use_checkpoints,omitempty"`     // nil uses the server default
+	UserCtx            UserCtx                `json:"user_ctx"`                      // see api/session
+	extra              extraFields
+}
+
+// UnmarshalJSON keeps the fields ReplicationConfig doesn't declare (eg: connection_timeout),
+// so a replicator recreated from its config keeps them
+func (r *ReplicationConfig) UnmarshalJSON(data []byte) error {
+	type config ReplicationConfig // avoid recursion
+	extra, err := unmarshalWithExtra(data, (*config)(r))
+	for name := range extra {
+		// special members (eg: _replication_stats) are set by the server and can't be saved
+		if strings.HasPrefix(name, "_") {
+			delete(extra, name)
+		}
+	}
+	if len(extra) == 0 {
+		extra = nil
+	}
+	r.extra = extra
+	return err
+}
+
+// MarshalJSON omits an empty user_ctx, letting the server set it
+func (r ReplicationConfig) MarshalJSON() ([]byte, error) {
+	type config ReplicationConfig // avoid recursion
+	doc := struct {
+		config
+		UserCtx *UserCtx `json:"user_ctx,omitempty"`
+	}{config: config(r)}
+	if r.UserCtx.Name != "" || r.UserCtx.Roles != nil {
+		doc.UserCtx = &r.UserCtx
+	}
+	return marshalWithExtra(doc, r.extra)
 }
 
 func (r ReplicationConfig) hasId() bool {
@@ -33,38 +117,79 @@ func (r ReplicationConfig) toJson() (io.Reader, error) {
 }
 
 func (r *ReplicationConfig) uniqueName() string {
-	return r.Source + r.Target
+	return r.Source.URL + r.Target.URL
 }
 
+// GenerateId sets the id using LegacyIds. See Couchdb.IdStrategy for other schemes.
 func (r *ReplicationConfig) GenerateId() {
-	hasher := md5.New()
-	hasher.Write([]byte(r.uniqueName()))
-	r.ID = fmt.Sprintf("%x", hasher.Sum(nil))
+	r.ID = LegacyIds(*r)
 }
 
 func (r ReplicationConfig) path() string {
 	if r.REV == "" {
-		return fmt.Sprintf("_replicator/%s", r.ID)
+		return fmt.Sprintf("_replicator/%s", escapeDocId(r.ID))
 	} else {
-		return fmt.Sprintf("_replicator/%s?rev=%s", r.ID, r.REV)
+		return fmt.Sprintf("_replicator/%s?rev=%s", escapeDocId(r.ID), url.QueryEscape(r.REV))
 	}
 }
 
 type Replicator struct {
 	ReplicationConfig
-	// following fields are set after doc creation
+	replicatorState
+}
+
+// replicatorState holds the fields set by the server after doc creation
+type replicatorState struct {
 	Owner                string `json:"owner,omitempty"`
 	ReplicationId        string `json:"_replication_id,omitempty"`
 	ReplicationState     string `json:"_replication_state,omitempty"`
 	ReplicationStateTime string `json:"_replication_state_time,omitempty"`
 }
 
+// UnmarshalJSON decodes the config and the state, which would otherwise be
+// hidden by the methods of the embedded ReplicationConfig
+func (r *Replicator) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.ReplicationConfig); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &r.replicatorState); err != nil {
+		return err
+	}
+	// the state isn't part of the config
+	for name := range jsonFields(reflect.TypeOf(r.replicatorState)) {
+		delete(r.ReplicationConfig.extra, name)
+	}
+	return nil
+}
+
+func (r Replicator) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(r.ReplicationConfig)
+	if err != nil {
+		return nil, err
+	}
+	var config extraFields
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return marshalWithExtra(r.replicatorState, config)
+}
+
 func (r Replicator) PP(printer util.Printer) {
 	printer.Print("[%s]", r.ID)
 	printer.Print(" %s → %s", r.Source, r.Target)
 	printer.Print(" Replication State: %s", r.ReplicationState)
 	printer.Print(" Continuous: %v", r.Continuous)
 	printer.Print(" Create Target: %v", r.CreateTarget)
+	if r.Filter != "" {
+		printer.Print(" Filter: %s", r.Filter)
+	}
+	if r.Selector != nil {
+		selector, _ := json.Marshal(r.Selector)
+		printer.Print(" Selector: %s", selector)
+	}
+	if len(r.DocIds) > 0 {
+		printer.Print(" Doc IDs: %s", strings.Join(r.DocIds, ", "))
+	}
 	printer.Print(" Replication State Time: %v", r.ReplicationStateTime)
 }
 
@@ -109,9 +234,9 @@ func (r replicatorDocs) path() string {
 
 func (r replicatorDocs) Replicators() *Replicators {
 	replicators := make(Replicators)
-	var ignoredPrefix = "_"
 	for _, row := range r.Rows {
-		if len(row.Id) > 0 && row.Id[0] == ignoredPrefix[0] {
+		// design documents aren't replicators
+		if strings.HasPrefix(row.Id, "_design/") {
 			continue
 		}
 		replicators.add(row.Replicator)
@@ -119,7 +244,7 @@ func (r replicatorDocs) Replicators() *Replicators {
 	return &replicators
 }
 
-func (c Couchdb) GetReplicators() (*Replicators, error) {
+func (c *Couchdb) GetReplicators() (*Replicators, error) {
 	docs := new(replicatorDocs)
 	err := c.getJson(docs, docs.path())
 	if err != nil {
@@ -129,81 +254,87 @@ func (c Couchdb) GetReplicators() (*Replicators, error) {
 
 }
 
-func (c Couchdb) GetReplicator(id string) (*Replicator, error) {
+func (c *Couchdb) GetReplicator(id string) (*Replicator, error) {
 	replicator := new(Replicator)
 	replicator.ID = id
 	err := c.getJson(replicator, replicator.path())
 	return replicator, err
 }
 
-func (c Couchdb) Replicate(conf ReplicationConfig) error {
-	jsonBody, err := conf.toJson()
-	if err != nil {
-		return err
-	}
+func (c *Couchdb) Replicate(conf ReplicationConfig) error {
 	if !conf.hasId() {
-		conf.GenerateId()
+		c.generateId(&conf)
 	}
-	jsonObj := new(interface{})
 	// newschool, creating doc in /_replicator
-	return c.putJson(jsonObj, jsonBody, conf.path())
+	_, err := c.saveReplicator(conf)
+	return err
 }
 
-func (c Couchdb) ReplicateHost(remoteCouch *Couchdb, conf ReplicationConfig) (*Databases, error) {
-	var replicatedDbs Databases
-	remoteDatabases, err := remoteCouch.GetDatabases()
+// saveReplicator puts the replicator doc, returning its new rev
+func (c *Couchdb) saveReplicator(conf ReplicationConfig) (string, error) {
+	jsonBody, err := conf.toJson()
 	if err != nil {
-		return &replicatedDbs, err
+		return "", err
 	}
-	replicators, err := c.GetReplicators()
-	if err != nil {
-		return &replicatedDbs, err
+	saved := new(struct {
+		Rev string `json:"rev"`
+	})
+	err = c.putJson(saved, jsonBody, conf.path())
+	return saved.Rev, err
+}
+
+type TransientReplication struct {
+	OK        bool   `json:"ok"`
+	LocalId   string `json:"_local_id,omitempty"` // replication id of continuous replications
+	SessionId string `json:"session_id,omitempty"`
+}
+
+func (t TransientReplication) PP(printer util.Printer) {
+	if t.LocalId != "" {
+		printer.Print("Replication ID: %s", t.LocalId)
+	} else {
+		printer.Print("Session ID: %s", t.SessionId)
 	}
-	session, err := c.GetSession()
+}
+
+// ReplicateTransient starts (or with conf.Cancel, cancels) a replication with the
+// oldschool /_replicate endpoint. These replications don't survive a server restart.
+// One-shot replications return once the replication has completed.
+func (c *Couchdb) ReplicateTransient(conf ReplicationConfig) (TransientReplication, error) {
+	conf.ID = ""
+	conf.REV = ""
+	result := TransientReplication{}
+	jsonBody, err := conf.toJson()
 	if err != nil {
-		return &replicatedDbs, err
+		return result, err
 	}
-	invalidPrefix := uint8('_')
-	for _, remoteDatabase := range remoteDatabases {
-		remoteDbName := *remoteDatabase.Name
-		if remoteDbName[0] == invalidPrefix {
-			continue
-		}
-		conf.UserCtx = session.UserCtx
-		conf.Source = remoteCouch.url(remoteDbName)
-		conf.Target = remoteDbName
-		conf.GenerateId()
-		existingReplicator, found := replicators.findById(conf.ID)
-		if found {
-			if existingReplicator.ReplicationState == "triggered" {
-				// not possible to update triggered replicators
-				continue
-			} else {
-				conf.REV = existingReplicator.REV
-			}
-		} else {
-			conf.REV = ""
-		}
-		err = c.Replicate(conf)
-		if err != nil {
-			return &replicatedDbs, err
-		}
-		replicatedDbs = append(replicatedDbs, remoteDatabase)
+	err = c.postJson(&result, jsonBody, "_replicate")
+	return result, err
+}
+
+// CancelTransientReplication cancels a /_replicate replication given the replication id
+// shown in _active_tasks
+func (c *Couchdb) CancelTransientReplication(replicationId string) error {
+	jsonBody, err := json.Marshal(map[string]interface{}{"replication_id": replicationId, "cancel": true})
+	if err != nil {
+		return err
 	}
-	return &replicatedDbs, nil
+	return c.postJson(new(interface{}), bytes.NewReader(jsonBody), "_replicate")
 }
 
-func (c Couchdb) DeleteReplicator(id string) error {
+func (c *Couchdb) DeleteReplicator(id string) error {
 	replicator, err := c.GetReplicator(id)
 	if err != nil {
 		return err
 	}
 	body, err := c.del(replicator.path())
-	body.Close()
-	return err
+	if err != nil {
+		return err
+	}
+	return body.Close()
 }
 
-func (c Couchdb) DeleteAllReplicators() (*Replicators, error) {
+func (c *Couchdb) DeleteAllReplicators() (*Replicators, error) {
 	replicators, err := c.GetReplicators()
 	if err != nil {
 		return replicators, err
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/awilliams/couchdb-utils/util"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ReplicateHostOptions selects the databases of a remote host to replicate and
+// whether they should also be replicated back.
+type ReplicateHostOptions struct {
+	Databases     DatabaseFilter
+	Bidirectional bool
+	// LocalURL is the url the remote server uses to reach this server when
+	// replicating back, required with Bidirectional. It can't be a loopback
+	// address, which the remote server would resolve to itself.
+	LocalURL string
+	// Atomic rolls back every replicator doc created or updated if any database fails
+	Atomic bool
+	// ContinueOnError attempts every database instead of stopping at the first failure
+	ContinueOnError bool
+}
+
+// Outcomes of the replicator doc of a database
+const (
+	ReplicatorCreated    = "created"
+	ReplicatorUpdated    = "updated"
+	ReplicatorSkipped    = "skipped" // triggered replicators can't be updated
+	ReplicatorFailed     = "failed"
+	ReplicatorRolledBack = "rolled back"
+)
+
+// HostReplicationOutcome is what happened to the replicators of a database.
+// Push is empty unless replicating bidirectionally.
+type HostReplicationOutcome struct {
+	Database Database
+	Pull     string
+	Push     string
+	Error    error
+}
+
+type HostReplicationOutcomes []HostReplicationOutcome
+
+func (h HostReplicationOutcomes) PP(printer util.Printer) {
+	printer.Print("%-40s %-12s %-12s %s", "DATABASE", "PULL", "PUSH", "ERROR")
+	for _, outcome := range h {
+		var err string
+		if outcome.Error != nil {
+			err = strings.Replace(outcome.Error.Error(), "\n", " ", -1)
+		}
+		printer.Print("%-40s %-12s %-12s %s", outcome.Database.String(), outcome.Pull, outcome.Push, err)
+	}
+}
+
+// Databases returns the databases whose replicators were all saved
+func (h HostReplicationOutcomes) Databases() Databases {
+	var databases Databases
+	for _, outcome := range h {
+		if outcome.Error == nil && outcome.Pull != ReplicatorRolledBack {
+			databases = append(databases, outcome.Database)
+		}
+	}
+	return databases
+}
+
+// Failed returns the number of databases which failed
+func (h HostReplicationOutcomes) Failed() int {
+	var failed int
+	for _, outcome := range h {
+		if outcome.Error != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// rolledBack marks the created and updated replicators as rolled back
+func (h HostReplicationOutcomes) rolledBack() {
+	for i := range h {
+		for _, outcome := range []*string{&h[i].Pull, &h[i].Push} {
+			if *outcome == ReplicatorCreated || *outcome == ReplicatorUpdated {
+				*outcome = ReplicatorRolledBack
+			}
+		}
+	}
+}
+
+// replicatorChange records a saved replicator doc so it can be rolled back
+type replicatorChange struct {
+	id       string
+	rev      string             // rev after saving
+	previous *ReplicationConfig // nil if the doc was created
+}
+
+// hostReplicator creates replicators on one server, skipping triggered ones
+type hostReplicator struct {
+	couch       *Couchdb
+	replicators *Replicators
+	userCtx     UserCtx
+	changes     []replicatorChange
+}
+
+func (c *Couchdb) newHostReplicator() (*hostReplicator, error) {
+	replicators, err := c.GetReplicators()
+	if err != nil {
+		return nil, err
+	}
+	session, err := c.GetSession()
+	if err != nil {
+		return nil, err
+	}
+	return &hostReplicator{couch: c, replicators: replicators, userCtx: session.UserCtx}, nil
+}
+
+func (h *hostReplicator) replicate(conf ReplicationConfig) (string, error) {
+	conf.UserCtx = h.userCtx
+	outcome := ReplicatorCreated
+	existingReplicator, found := h.replicators.findById(conf.ID)
+	if found {
+		if existingReplicator.ReplicationState == "triggered" {
+			// not possible to update triggered replicators
+			return ReplicatorSkipped, nil
+		}
+		conf.REV = existingReplicator.REV
+		outcome = ReplicatorUpdated
+	} else {
+		conf.REV = ""
+	}
+	rev, err := h.couch.saveReplicator(conf)
+	if err != nil {
+		return ReplicatorFailed, err
+	}
+	change := replicatorChange{id: conf.ID, rev: rev}
+	if found {
+		previous := existingReplicator.ReplicationConfig
+		change.previous = &previous
+	}
+	h.changes = append(h.changes, change)
+	return outcome, nil
+}
+
+// rollbackTimeout bounds the rollback, which isn't cancelled with the context of the client
+const rollbackTimeout = time.Minute
+
+// rollback deletes the created replicator docs and restores the updated ones,
+// latest first, returning the errors of the docs which couldn't be rolled back.
+// It isn't cancelled with the context of the client, which may have caused the failure.
+func (h *hostReplicator) rollback() []error {
+	var errors []error
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+	couch := h.couch.WithContext(ctx)
+	for i := len(h.changes) - 1; i >= 0; i-- {
+		change := h.changes[i]
+		var err error
+		if change.previous == nil {
+			err = couch.deleteJson(new(interface{}), ReplicationConfig{ID: change.id, REV: change.rev}.path())
+		} else {
+			restored := *change.previous
+			restored.REV = change.rev
+			_, err = couch.saveReplicator(restored)
+		}
+		if err != nil {
+			errors = append(errors, fmt.Errorf("%s: %v", change.id, err))
+		}
+	}
+	h.changes = nil
+	return errors
+}
+
+// newLocalCouch returns the client of LocalURL, checking the remote server can reach it
+func newLocalCouch(localURL string) (*Couchdb, error) {
+	if localURL == "" {
+		return nil, fmt.Errorf("the url of this server as seen from the remote host is required to replicate back")
+	}
+	localCouch, err := New(localURL)
+	if err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(localCouch.URL()); err == nil && isLoopback(u.Hostname()) {
+		return nil, fmt.Errorf("%s is a loopback address, the remote host would replicate from itself", u.Hostname())
+	}
+	return localCouch, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ReplicateHost replicates the databases of the server at remoteURL to this server.
+// The remote server is requested with the same options as this one.
+func (c *Couchdb) ReplicateHost(remoteURL string, conf ReplicationConfig, opts ReplicateHostOptions) (HostReplicationOutcomes, error) {
+	var outcomes HostReplicationOutcomes
+	remoteCouch, err := c.withURL(remoteURL)
+	if err != nil {
+		return outcomes, err
+	}
+	remoteDatabases, err := remoteCouch.GetDatabases()
+	if err != nil {
+		return outcomes, err
+	}
+	pull, err := c.newHostReplicator()
+	if err != nil {
+		return outcomes, err
+	}
+	var push *hostReplicator
+	var localCouch *Couchdb
+	if opts.Bidirectional {
+		if localCouch, err = newLocalCouch(opts.LocalURL); err != nil {
+			return outcomes, err
+		}
+		if push, err = remoteCouch.newHostReplicator(); err != nil {
+			return outcomes, err
+		}
+	}
+	for _, remoteDatabase := range remoteDatabases.Filter(opts.Databases) {
+		remoteDbName := *remoteDatabase.Name
+		outcome := HostReplicationOutcome{Database: remoteDatabase}
+		pullConf := conf
+		pullConf.Source.URL = remoteCouch.url(remoteDatabase.path())
+		pullConf.Target.URL = remoteDbName
+		c.generateId(&pullConf)
+		outcome.Pull, outcome.Error = pull.replicate(pullConf)
+		if opts.Bidirectional && outcome.Error == nil {
+			// the endpoints are swapped, along with their auth and headers
+			pushConf := conf
+			pushConf.Source, pushConf.Target = conf.Target, conf.Source
+			pushConf.Source.URL = localCouch.url(remoteDatabase.path())
+			pushConf.Target.URL = remoteDbName
+			c.generateId(&pushConf)
+			// both servers may hold replicators of the same database,
+			// prefix the id so they are distinct
+			pushConf.ID = "push-" + pushConf.ID
+			outcome.Push, outcome.Error = push.replicate(pushConf)
+		}
+		outcomes = append(outcomes, outcome)
+		if outcome.Error != nil && !opts.ContinueOnError {
+			break
+		}
+	}
+	failed := outcomes.Failed()
+	if failed == 0 {
+		return outcomes, nil
+	}
+	err = fmt.Errorf("%d of %d databases failed", failed, len(outcomes))
+	if !opts.ContinueOnError {
+		err = outcomes[len(outcomes)-1].Error
+	}
+	if opts.Atomic {
+		rollbackErrors := pull.rollback()
+		if push != nil {
+			rollbackErrors = append(rollbackErrors, push.rollback()...)
+		}
+		if len(rollbackErrors) != 0 {
+			return outcomes, fmt.Errorf("%v\nrollback failed for %s", err, joinErrors(rollbackErrors))
+		}
+		outcomes.rolledBack()
+	}
+	return outcomes, err
+}
+
+func joinErrors(errors []error) string {
+	messages := make([]string, len(errors))
+	for i, err := range errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, ", ")
+}
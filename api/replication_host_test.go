@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+)
+
+// newHostServer serves the given databases, with an existing replicator of b,
+// failing to save the replicator of c
+func newHostServer(databases string) (*httptest.Server, *Couchdb, *[]string) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/_all_dbs":
+			fmt.Fprint(w, databases)
+		case r.URL.Path == "/_session":
+			fmt.Fprint(w, `{"ok":true,"userCtx":{"name":"admin","roles":["_admin"]}}`)
+		case r.URL.Path == "/_replicator/_all_docs":
+			fmt.Fprint(w, `{"rows":[{"id":"b","doc":{"_id":"b","_rev":"1-b","source":"http://old/b","target":"b","_replication_state":"error"}}]}`)
+		case r.URL.Path == "/_replicator/c":
+			w.WriteHeader(403)
+			fmt.Fprint(w, `{"error":"forbidden","reason":"nope"}`)
+		default:
+			requests = append(requests, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+			w.WriteHeader(201)
+			fmt.Fprintf(w, `{"ok":true,"rev":"2-%s"}`, path.Base(r.URL.Path))
+		}
+	}))
+	couchdb, _ := New(ts.URL)
+	couchdb.IdStrategy = func(conf ReplicationConfig) string {
+		return conf.Target.URL
+	}
+	return ts, couchdb, &requests
+}
+
+func TestReplicateHostAtomic(t *testing.T) {
+	ts, couchdb, requests := newHostServer(`["a","b","c"]`)
+	defer ts.Close()
+	outcomes, err := couchdb.ReplicateHost(couchdb.URL(), ReplicationConfig{}, ReplicateHostOptions{Atomic: true})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if len(outcomes) != 3 || outcomes[0].Pull != ReplicatorRolledBack || outcomes[1].Pull != ReplicatorRolledBack || outcomes[2].Pull != ReplicatorFailed {
+		t.Fatalf("Outcomes incorrect: %#v", outcomes)
+	}
+	if len(*requests) != 4 {
+		t.Fatalf("Expected 4 requests, Actual:\n%s", strings.Join(*requests, "\n"))
+	}
+	if !strings.HasPrefix((*requests)[2], "PUT /_replicator/b?rev=2-b ") || !strings.Contains((*requests)[2], `"source":"http://old/b"`) {
+		t.Fatalf("Expected b to be restored, Actual: %s", (*requests)[2])
+	}
+	if !strings.HasPrefix((*requests)[3], "DELETE /_replicator/a?rev=2-a") {
+		t.Fatalf("Expected a to be deleted, Actual: %s", (*requests)[3])
+	}
+	if databases := outcomes.Databases(); len(databases) != 0 {
+		t.Fatalf("Expected no replicated databases, Actual: %v", databases)
+	}
+}
+
+func TestReplicateHostContinueOnError(t *testing.T) {
+	ts, couchdb, requests := newHostServer(`["a","c","b"]`)
+	defer ts.Close()
+	outcomes, err := couchdb.ReplicateHost(couchdb.URL(), ReplicationConfig{}, ReplicateHostOptions{ContinueOnError: true})
+	if err == nil || err.Error() != "1 of 3 databases failed" {
+		t.Fatalf("Expected error of 1 failed database, Actual: %v", err)
+	}
+	expected := []string{ReplicatorCreated, ReplicatorFailed, ReplicatorUpdated}
+	for i, outcome := range outcomes {
+		if outcome.Pull != expected[i] {
+			t.Fatalf("Outcome of %s incorrect. Expected: %s, Actual: %s", outcome.Database.String(), expected[i], outcome.Pull)
+		}
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, Actual:\n%s", strings.Join(*requests, "\n"))
+	}
+	if databases := outcomes.Databases(); len(databases) != 2 {
+		t.Fatalf("Expected 2 replicated databases, Actual: %v", databases)
+	}
+}
+
+func TestReplicateHostBidirectional(t *testing.T) {
+	local, localCouch, localRequests := newHostServer(`[]`)
+	defer local.Close()
+	remote, remoteCouch, remoteRequests := newHostServer(`["a"]`)
+	defer remote.Close()
+	conf := ReplicationConfig{
+		Source: ReplicationEndpoint{Auth: &EndpointAuth{Basic: &BasicAuth{Username: "remote", Password: "r"}}},
+		Target: ReplicationEndpoint{Headers: map[string]string{"X-Local": "1"}},
+	}
+
+	for _, localURL := range []string{"", "localhost:5984", "http://127.0.0.1:5984"} {
+		_, err := localCouch.ReplicateHost(remoteCouch.URL(), conf, ReplicateHostOptions{Bidirectional: true, LocalURL: localURL})
+		if err == nil {
+			t.Fatalf("Expected error for local url %q", localURL)
+		}
+	}
+	outcomes, err := localCouch.ReplicateHost(remoteCouch.URL(), conf, ReplicateHostOptions{Bidirectional: true, LocalURL: "http://local.example.com:5984"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outcomes) != 1 || outcomes[0].Pull != ReplicatorCreated || outcomes[0].Push != ReplicatorCreated {
+		t.Fatalf("Outcomes incorrect: %#v", outcomes)
+	}
+	if len(*localRequests) != 1 || len(*remoteRequests) != 1 {
+		t.Fatalf("Expected 1 request to each server, Actual:\n%s\n%s", strings.Join(*localRequests, "\n"), strings.Join(*remoteRequests, "\n"))
+	}
+	// the pull id is the same as without --bidirectional
+	pull := (*localRequests)[0]
+	if !strings.HasPrefix(pull, "PUT /_replicator/a ") || !strings.Contains(pull, `"source":{"url":"`+remote.URL+`/a","auth":{"basic":{"username":"remote"`) || !strings.Contains(pull, `"target":{"url":"a","headers":{"X-Local":"1"}}`) {
+		t.Fatalf("Pull replicator incorrect: %s", pull)
+	}
+	push := (*remoteRequests)[0]
+	if !strings.HasPrefix(push, "PUT /_replicator/push-a ") || !strings.Contains(push, `"source":{"url":"http://local.example.com:5984/a","headers":{"X-Local":"1"}}`) || !strings.Contains(push, `"target":{"url":"a","auth":{"basic":{"username":"remote"`) {
+		t.Fatalf("Push replicator incorrect: %s", push)
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// IdStrategy generates the _replicator document id of a replication
+type IdStrategy func(conf ReplicationConfig) string
+
+// LegacyIds hashes the source and target urls as given, including any credentials.
+// This is the scheme used by GenerateId.
+func LegacyIds(conf ReplicationConfig) string {
+	return md5Hex(conf.uniqueName())
+}
+
+// HashIds hashes the source and target urls without credentials, so the id
+// doesn't change when a password does.
+func HashIds(conf ReplicationConfig) string {
+	return md5Hex(stripCredentials(conf.Source.URL) + stripCredentials(conf.Target.URL))
+}
+
+// ReadableIds builds ids of the form `remote-5984-orders_to_orders`
+func ReadableIds(conf ReplicationConfig) string {
+	return readableLabel(conf.Source.URL) + "_to_" + readableLabel(conf.Target.URL)
+}
+
+var idStrategies = map[string]IdStrategy{
+	"legacy":   LegacyIds,
+	"hash":     HashIds,
+	"readable": ReadableIds,
+}
+
+// IdStrategyNames returns the names accepted by IdStrategyByName
+func IdStrategyNames() []string {
+	var names []string
+	for name := range idStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func IdStrategyByName(name string) (IdStrategy, error) {
+	strategy, found := idStrategies[name]
+	if !found {
+		return nil, fmt.Errorf("Unknown id scheme %q, must be one of: %s", name, strings.Join(IdStrategyNames(), ", "))
+	}
+	return strategy, nil
+}
+
+func md5Hex(s string) string {
+	hasher := md5.New()
+	hasher.Write([]byte(s))
+	return fmt.Sprintf("%x", hasher.Sum(nil))
+}
+
+func stripCredentials(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.User == nil {
+		return rawurl
+	}
+	u.User = nil
+	return u.String()
+}
+
+var unreadableIdChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func readableLabel(rawurl string) string {
+	label := rawurl
+	if u, err := url.Parse(rawurl); err == nil && u.Host != "" {
+		label = u.Host + "/" + strings.TrimPrefix(u.Path, "/")
+	}
+	// ids may not begin with '_'
+	return strings.TrimLeft(unreadableIdChars.ReplaceAllString(label, "-"), "_-")
+}
+
+func (c *Couchdb) generateId(conf *ReplicationConfig) {
+	strategy := c.IdStrategy
+	if strategy == nil {
+		strategy = LegacyIds
+	}
+	conf.ID = strategy(*conf)
+}
+
+// generatedIdPrefix returns the prefix of an id generated by one of the known
+// strategies, eg: "push-" for the push replicators of `rep host --bidirectional`.
+// Ids which weren't generated, eg: given with `rep start --id`, aren't found.
+func generatedIdPrefix(conf ReplicationConfig) (string, bool) {
+	for _, prefix := range []string{"", "push-"} {
+		if !strings.HasPrefix(conf.ID, prefix) {
+			continue
+		}
+		for _, strategy := range idStrategies {
+			if prefix+strategy(conf) == conf.ID {
+				return prefix, true
+			}
+		}
+	}
+	return "", false
+}
+
+// PlanIdMigration returns the changes needed to move the replicators with generated
+// ids to the ids of the given strategy. Replicators with custom ids are left alone.
+// Replicators are deleted and then recreated with the new id.
+// As the replication id CouchDB uses for checkpoints doesn't depend on the document id,
+// the recreated replication resumes from its last checkpoint.
+// An error is returned if a new id is already taken, as strategies ignoring eg: filters
+// may give the same id to different replications.
+func (c *Couchdb) PlanIdMigration(strategy IdStrategy) (ReplicationPlan, error) {
+	var plan ReplicationPlan
+	replicators, err := c.GetReplicators()
+	if err != nil {
+		return plan, err
+	}
+	var all []*Replicator
+	for _, subReplicators := range *replicators {
+		all = append(all, subReplicators...)
+	}
+	sort.Sort(replicatorsById(all))
+	newIds := make(map[string]string)
+	taken := make(map[string]string)
+	for _, replicator := range all {
+		prefix, generated := generatedIdPrefix(replicator.ReplicationConfig)
+		newId := prefix + strategy(replicator.ReplicationConfig)
+		if !generated || newId == replicator.ID {
+			taken[replicator.ID] = replicator.ID
+			continue
+		}
+		newIds[replicator.ID] = newId
+	}
+	for _, replicator := range all {
+		newId, found := newIds[replicator.ID]
+		if !found {
+			continue
+		}
+		if other, isTaken := taken[newId]; isTaken {
+			return nil, fmt.Errorf("Replicators %s and %s would both have the id %s, the scheme doesn't tell them apart", other, replicator.ID, newId)
+		}
+		taken[newId] = replicator.ID
+		conf := replicator.ReplicationConfig
+		conf.ID = newId
+		conf.REV = ""
+		// create before deleting, so the replicator isn't lost if it can't be created
+		plan = append(plan,
+			ReplicationAction{Action: PlanCreate, Config: conf},
+			ReplicationAction{Action: PlanDelete, Config: replicator.ReplicationConfig, Existing: replicator})
+	}
+	return plan, nil
+}
+
+type replicatorsById []*Replicator
+
+func (a replicatorsById) Len() int           { return len(a) }
+func (a replicatorsById) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a replicatorsById) Less(i, j int) bool { return a[i].ID < a[j].ID }
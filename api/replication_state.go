@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// pausedReplicator is stored as a _local document in _replicator while a
+// replication is paused. _local documents are ignored by the replicator.
+type pausedReplicator struct {
+	REV        string            `json:"_rev,omitempty"`
+	Replicator ReplicationConfig `json:"replicator"`
+}
+
+func pausedPath(id string) string {
+	return "_replicator/" + escapeDocId("_local/paused-"+id)
+}
+
+// checkpointedSeq returns the last source sequence checkpointed by the
+// running replication of the given replicator, or nil if it isn't running
+func (c *Couchdb) checkpointedSeq(id string) (interface{}, error) {
+	activeTasks, err := c.GetActiveTasks()
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range activeTasks.ByType("replication") {
+		if task.DocId == id {
+			return task.CheckpointedSourceSeq, nil
+		}
+	}
+	return nil, nil
+}
+
+// pause saves the config as a _local document and deletes the replicator,
+// returning the saved document
+func (c *Couchdb) pause(replicator *Replicator, conf ReplicationConfig) (pausedReplicator, error) {
+	paused := pausedReplicator{Replicator: conf}
+	paused.Replicator.REV = ""
+	jsonBody, err := json.Marshal(paused)
+	if err != nil {
+		return paused, err
+	}
+	// save before deleting, so the config isn't lost if saving fails
+	saved := new(struct {
+		Rev string `json:"rev"`
+	})
+	if err = c.putJson(saved, bytes.NewReader(jsonBody), pausedPath(replicator.ID)); err != nil {
+		return paused, err
+	}
+	paused.REV = saved.Rev
+	return paused, c.deleteJson(new(interface{}), replicator.path())
+}
+
+// resume recreates the paused replicator and deletes its _local document
+func (c *Couchdb) resume(id string, paused pausedReplicator) error {
+	if err := c.Replicate(paused.Replicator); err != nil {
+		return err
+	}
+	return c.deleteJson(new(interface{}), pausedPath(id)+"?rev="+url.QueryEscape(paused.REV))
+}
+
+// replaceReplicator deletes the replicator and creates conf in its place. The
+// replicator is paused meanwhile, so its config isn't lost if conf can't be created.
+func (c *Couchdb) replaceReplicator(replicator *Replicator, conf ReplicationConfig) error {
+	paused, err := c.pause(replicator, replicator.ReplicationConfig)
+	if err != nil {
+		return err
+	}
+	conf.REV = ""
+	if err = c.Replicate(conf); err != nil {
+		return fmt.Errorf("%v\n%s is kept paused until resumed", err, replicator.ID)
+	}
+	return c.deleteJson(new(interface{}), pausedPath(replicator.ID)+"?rev="+url.QueryEscape(paused.REV))
+}
+
+// RestartReplicator deletes and recreates the replicator with its original config.
+// This also works for triggered replicators, which can't be updated.
+// If sinceCheckpoint, it starts from the last checkpointed sequence.
+func (c *Couchdb) RestartReplicator(id string, sinceCheckpoint bool) error {
+	replicator, err := c.GetReplicator(id)
+	if err != nil {
+		return err
+	}
+	conf := replicator.ReplicationConfig
+	if sinceCheckpoint {
+		seq, err := c.checkpointedSeq(id)
+		if err != nil {
+			return err
+		}
+		if seq != nil {
+			conf.SinceSeq = seq
+		}
+	}
+	// resume from the config in memory, a dry run doesn't save it
+	paused, err := c.pause(replicator, conf)
+	if err != nil {
+		return err
+	}
+	if err = c.resume(id, paused); err != nil {
+		return fmt.Errorf("%v\n%s is kept paused until resumed", err, id)
+	}
+	return nil
+}
+
+// PauseReplicator deletes the replicator, keeping its config so it can be resumed with ResumeReplicator
+func (c *Couchdb) PauseReplicator(id string, sinceCheckpoint bool) error {
+	replicator, err := c.GetReplicator(id)
+	if err != nil {
+		return err
+	}
+	conf := replicator.ReplicationConfig
+	if sinceCheckpoint {
+		seq, err := c.checkpointedSeq(id)
+		if err != nil {
+			return err
+		}
+		if seq != nil {
+			conf.SinceSeq = seq
+		}
+	}
+	_, err = c.pause(replicator, conf)
+	return err
+}
+
+// ResumeReplicator recreates a replicator paused with PauseReplicator
+func (c *Couchdb) ResumeReplicator(id string) error {
+	paused := new(pausedReplicator)
+	if err := c.getJson(paused, pausedPath(id)); err != nil {
+		return err
+	}
+	return c.resume(id, *paused)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries requests which fail with a connection error or with
+// HTTP 429, 500, 502 or 503, waiting an exponentially growing delay with jitter.
+// POST requests are only retried if the connection couldn't be established,
+// unless RetryPosts is set, as they aren't idempotent.
+type RetryPolicy struct {
+	MaxAttempts int           // including the first, 1 or less disables retries
+	Backoff     time.Duration // delay before the first retry, doubled after each one
+	MaxBackoff  time.Duration // 0 means no limit
+	RetryPosts  bool
+}
+
+var retryStatusCodes = map[int]bool{
+	429: true,
+	500: true,
+	502: true,
+	503: true,
+}
+
+// delay returns the wait before the given retry (1 for the first), between half and all of the backoff
+func (p RetryPolicy) delay(retry int) time.Duration {
+	backoff := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff == 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff != 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+}
+
+// retryAfter returns the delay requested by the server with a Retry-After header (in seconds)
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// isDialError returns true if the request was never sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// shouldRetry decides whether a failed attempt is retried
+func (p RetryPolicy) shouldRetry(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		return method != POST || p.RetryPosts || isDialError(err)
+	}
+	return retryStatusCodes[resp.StatusCode] && (method != POST || p.RetryPosts)
+}
+
+// rewind seeks the body back to its start so it can be sent again,
+// returning false if the body can't be replayed
+func rewind(body io.Reader) bool {
+	if body == nil {
+		return true
+	}
+	if sized, ok := body.(sizedReader); ok {
+		body = sized.Reader
+	}
+	seeker, ok := body.(io.Seeker)
+	if !ok {
+		return false
+	}
+	_, err := seeker.Seek(0, io.SeekStart)
+	return err == nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	cases := map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 10: 300 * time.Millisecond}
+	for retry, backoff := range cases {
+		delay := policy.delay(retry)
+		if delay < backoff/2 || delay > backoff {
+			t.Fatalf("delay(%d) = %v, expected between %v and %v", retry, delay, backoff/2, backoff)
+		}
+	}
+}
+
+func newFlakyServer(failures int, status int) (*httptest.Server, *int, *[]string) {
+	attempts := 0
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if attempts <= failures {
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"error":"unavailable","reason":"try again"}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	return ts, &attempts, &bodies
+}
+
+func TestRetry(t *testing.T) {
+	ts, attempts, bodies := newFlakyServer(2, 503)
+	defer ts.Close()
+	couchdb, _ := New(ts.URL)
+	couchdb.Retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	var result map[string]bool
+	if err := couchdb.putJson(&result, bytes.NewReader([]byte(`{"a":1}`)), "db/doc"); err != nil {
+		t.Fatal(err)
+	}
+	if *attempts != 3 {
+		t.Fatalf("Expected 3 attempts, Actual: %d", *attempts)
+	}
+	for _, body := range *bodies {
+		if body != `{"a":1}` {
+			t.Fatalf("Body not replayed: %q", body)
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	ts, attempts, _ := newFlakyServer(5, 500)
+	defer ts.Close()
+	couchdb, _ := New(ts.URL)
+	couchdb.Retry = RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+	err := couchdb.getJson(new(interface{}), "")
+	if couchErr, ok := err.(CouchdbError); !ok || couchErr.Status != 500 {
+		t.Fatalf("Expected HTTP 500 error, Actual: %v", err)
+	}
+	if *attempts != 2 {
+		t.Fatalf("Expected 2 attempts, Actual: %d", *attempts)
+	}
+}
+
+func TestNoRetryOfPost(t *testing.T) {
+	ts, attempts, _ := newFlakyServer(1, 503)
+	defer ts.Close()
+	couchdb, _ := New(ts.URL)
+	couchdb.Retry = RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	if err := couchdb.postJson(new(interface{}), bytes.NewReader([]byte(`{}`)), "_replicate"); err == nil {
+		t.Fatal("Expected error")
+	}
+	if *attempts != 1 {
+		t.Fatalf("Expected 1 attempt, Actual: %d", *attempts)
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/awilliams/couchdb-utils/util"
+	"strings"
+)
+
+type SecurityGroup struct {
+	Names []string `json:"names"`
+	Roles []string `json:"roles"`
+}
+
+func (g SecurityGroup) IsEmpty() bool {
+	return len(g.Names) == 0 && len(g.Roles) == 0
+}
+
+func (g SecurityGroup) String() string {
+	return "names: " + strings.Join(g.Names, ", ") + " roles: " + strings.Join(g.Roles, ", ")
+}
+
+// Add adds the name and role, either of which may be empty
+func (g *SecurityGroup) Add(name, role string) {
+	if name != "" && !contains(g.Names, name) {
+		g.Names = append(g.Names, name)
+	}
+	if role != "" && !contains(g.Roles, role) {
+		g.Roles = append(g.Roles, role)
+	}
+}
+
+// Remove removes the name and role, either of which may be empty
+func (g *SecurityGroup) Remove(name, role string) {
+	g.Names = without(g.Names, name)
+	g.Roles = without(g.Roles, role)
+}
+
+// normalize replaces nil lists, which CouchDB rejects as null
+func (g *SecurityGroup) normalize() {
+	if g.Names == nil {
+		g.Names = []string{}
+	}
+	if g.Roles == nil {
+		g.Roles = []string{}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func without(list []string, s string) []string {
+	var filtered []string
+	for _, item := range list {
+		if item != s {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// Security is the security object of a database
+type Security struct {
+	Database Database      `json:"-"`
+	Admins   SecurityGroup `json:"admins"`
+	Members  SecurityGroup `json:"members"`
+	extra    extraFields
+}
+
+func (s *Security) UnmarshalJSON(data []byte) error {
+	type security Security // avoid recursion
+	extra, err := unmarshalWithExtra(data, (*security)(s))
+	s.extra = extra
+	return err
+}
+
+// MarshalJSON keeps the keys of the security object which Security doesn't
+// declare, eg: those set by other tools
+func (s Security) MarshalJSON() ([]byte, error) {
+	type security Security // avoid recursion
+	return marshalWithExtra(security(s), s.extra)
+}
+
+// IsPublic returns true if the security object has no members, in which case
+// anyone can read and write the database
+func (s Security) IsPublic() bool {
+	return s.Members.IsEmpty()
+}
+
+func (s Security) PP(printer util.Printer) {
+	header := "[" + s.Database.String() + "]"
+	if s.IsPublic() {
+		header += " (public)"
+	}
+	printer.Print(header)
+	printer.Print(" Admins: %s", s.Admins)
+	printer.Print(" Members: %s", s.Members)
+}
+
+type Securities []Security
+
+func (s Securities) PP(printer util.Printer) {
+	for _, security := range s {
+		security.PP(printer)
+	}
+}
+
+func (s Security) path() string {
+	return s.Database.path() + "/_security"
+}
+
+func (c *Couchdb) GetSecurity(db Database) (Security, error) {
+	security := Security{Database: db}
+	err := c.getJson(&security, security.path())
+	return security, err
+}
+
+func (c *Couchdb) SetSecurity(security Security) error {
+	security.Admins.normalize()
+	security.Members.normalize()
+	jsonBody, err := json.Marshal(security)
+	if err != nil {
+		return err
+	}
+	return c.putJson(new(interface{}), bytes.NewReader(jsonBody), security.path())
+}
+
+// AuditSecurity returns the security objects of the public databases matching the filter
+func (c *Couchdb) AuditSecurity(filter DatabaseFilter) (Securities, error) {
+	var public Securities
+	databases, err := c.GetDatabases()
+	if err != nil {
+		return public, err
+	}
+	for _, db := range databases.Filter(filter) {
+		security, err := c.GetSecurity(db)
+		if err != nil {
+			return public, err
+		}
+		if security.IsPublic() {
+			public = append(public, security)
+		}
+	}
+	return public, nil
+}
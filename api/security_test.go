@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecurityGroup(t *testing.T) {
+	var group SecurityGroup
+	group.Add("bob", "")
+	group.Add("bob", "staff")
+	if strings.Join(group.Names, ",") != "bob" || strings.Join(group.Roles, ",") != "staff" {
+		t.Fatalf("Add incorrect: %#v", group)
+	}
+	group.Remove("bob", "")
+	if len(group.Names) != 0 || len(group.Roles) != 1 {
+		t.Fatalf("Remove incorrect: %#v", group)
+	}
+}
+
+func TestGetSecurityPublic(t *testing.T) {
+	ts, couchdb := newTestingServer(200, `{}`)
+	defer ts.Close()
+	name := "open"
+	security, err := couchdb.GetSecurity(Database{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !security.IsPublic() {
+		t.Fatal("Empty security object should be public")
+	}
+	if security.Database.String() != "open" {
+		t.Fatalf("Expected: %s, Actual: %s", "open", security.Database.String())
+	}
+}
+
+func TestSetSecurityKeepsUnknownKeys(t *testing.T) {
+	var saved string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			body, _ := ioutil.ReadAll(r.Body)
+			saved = string(body)
+		}
+		fmt.Fprint(w, `{"admins":{"names":[],"roles":[]},"members":{"names":["bob"],"roles":[]},"couchdb_auth_only":true}`)
+	}))
+	defer ts.Close()
+	couchdb, _ := New(ts.URL)
+	name := "db"
+	security, err := couchdb.GetSecurity(Database{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	security.Members.Add("alice", "")
+	if err = couchdb.SetSecurity(security); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"admins":{"names":[],"roles":[]},"couchdb_auth_only":true,"members":{"names":["bob","alice"],"roles":[]}}`
+	if saved != expected {
+		t.Fatalf("Expected: %s, Actual: %s", expected, saved)
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"github.com/awilliams/couchdb-utils/util"
+	"strings"
 )
 
 type Server struct {
@@ -27,6 +28,11 @@ func (s *Server) String() string {
 	}
 }
 
+// Is1x is true for 1.x (and older) servers, which have no /_up or /_node endpoints
+func (s *Server) Is1x() bool {
+	return strings.HasPrefix(s.Version, "1.") || strings.HasPrefix(s.Version, "0.")
+}
+
 func (s Server) PP(printer util.Printer) {
 	if s.Vendor.Name == "" {
 		printer.Print("v%s\n%s", s.Version, s.Couchdb)
@@ -35,8 +41,19 @@ func (s Server) PP(printer util.Printer) {
 	}
 }
 
-func (c Couchdb) GetServer() (Server, error) {
+func (c *Couchdb) GetServer() (Server, error) {
 	server := new(Server)
 	err := c.getJson(server, server.path())
 	return *server, err
 }
+
+// Up is the response of /_up, available since 2.0
+type Up struct {
+	Status string `json:"status"`
+}
+
+func (c *Couchdb) GetUp() (Up, error) {
+	up := new(Up)
+	err := c.getJson(up, "_up")
+	return *up, err
+}
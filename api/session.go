@@ -24,7 +24,7 @@ func (s *Session) path() string {
 	return "_session"
 }
 
-func (c Couchdb) GetSession() (Session, error) {
+func (c *Couchdb) GetSession() (Session, error) {
 	session := new(Session)
 	err := c.getJson(session, session.path())
 	return *session, err
@@ -3,7 +3,9 @@ package api
 import (
 	"fmt"
 	"github.com/awilliams/couchdb-utils/util"
+	"sort"
 	"strings"
+	"time"
 )
 
 type Stat struct {
@@ -44,6 +46,52 @@ func (s Stats) PP(printer util.Printer) {
 	}
 }
 
+func (s Stats) Len() int      { return len(s) }
+func (s Stats) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s Stats) Less(i, j int) bool {
+	if s[i].Section == s[j].Section {
+		return s[i].SubSection < s[j].SubSection
+	}
+	return s[i].Section < s[j].Section
+}
+
+// StatDelta is the change of a stat's current value between two samples
+type StatDelta struct {
+	Stat
+	Delta float64
+	Rate  float64 // per second
+}
+
+type StatDeltas []StatDelta
+
+func (s StatDeltas) PP(printer util.Printer) {
+	printer.Print("%-45s %14s %10s %10s", "STAT", "CURRENT", "DELTA", "RATE/S")
+	for _, d := range s {
+		printer.Print("%-45s %14v %10v %10.2f", d.Section+":"+d.SubSection, d.Current, d.Delta, d.Rate)
+	}
+}
+
+// Since compares the stats with an earlier sample taken elapsed ago
+func (s Stats) Since(prev Stats, elapsed time.Duration) StatDeltas {
+	previous := make(map[string]Stat)
+	for _, stat := range prev {
+		previous[stat.Section+":"+stat.SubSection] = stat
+	}
+	sorted := append(Stats(nil), s...)
+	sort.Sort(sorted)
+	deltas := make(StatDeltas, len(sorted))
+	for i, stat := range sorted {
+		deltas[i].Stat = stat
+		if p, found := previous[stat.Section+":"+stat.SubSection]; found {
+			deltas[i].Delta = stat.Current - p.Current
+		}
+		if elapsed > 0 {
+			deltas[i].Rate = deltas[i].Delta / elapsed.Seconds()
+		}
+	}
+	return deltas
+}
+
 type statsJson map[string]map[string]Stat
 
 func (s *statsJson) path(sectionA, sectionB string) string {
@@ -51,14 +99,18 @@ func (s *statsJson) path(sectionA, sectionB string) string {
 	if sectionA == "" && sectionB == "" {
 		return base
 	} else {
-		return base + "/" + sectionA + "/" + sectionB
+		return base + "/" + escapeSegment(sectionA) + "/" + escapeSegment(sectionB)
 	}
 }
 
-func (c Couchdb) GetStats(sectionA, sectionB string) (Stats, error) {
+func (c *Couchdb) GetStats(sectionA, sectionB string) (Stats, error) {
 	statsMap := new(statsJson)
 	var stats Stats
 	err := c.getJson(statsMap, statsMap.path(sectionA, sectionB))
+	if couchErr, ok := AsCouchdbError(err); ok && (couchErr.IsNotFound() || couchErr.Status == 400) {
+		// 2.x moved stats to the node
+		return c.getNodeStats(sectionA, sectionB)
+	}
 	for section, _stats := range *statsMap {
 		for subSection, stat := range _stats {
 			stat.Section = section
@@ -68,3 +120,53 @@ func (c Couchdb) GetStats(sectionA, sectionB string) (Stats, error) {
 	}
 	return stats, err
 }
+
+func nodeStatsPath(sectionA, sectionB string) string {
+	base := "_node/_local/_stats"
+	if sectionA == "" && sectionB == "" {
+		return base
+	} else {
+		return base + "/" + escapeSegment(sectionA) + "/" + escapeSegment(sectionB)
+	}
+}
+
+func (c *Couchdb) getNodeStats(sectionA, sectionB string) (Stats, error) {
+	var stats Stats
+	node := make(map[string]interface{})
+	err := c.getJson(&node, nodeStatsPath(sectionA, sectionB))
+	if err != nil {
+		return stats, err
+	}
+	var prefix []string
+	if sectionA != "" {
+		prefix = []string{sectionA, sectionB}
+	}
+	flattenNodeStats(prefix, node, &stats)
+	return stats, nil
+}
+
+// flattenNodeStats converts the nested 2.x stats into Stats. Leaves have a type and
+// a value; the section is the first key of the path, the subsection the rest joined by '.'
+func flattenNodeStats(path []string, node map[string]interface{}, stats *Stats) {
+	if _, isLeaf := node["type"]; isLeaf && len(path) > 1 {
+		stat := Stat{Section: path[0], SubSection: strings.Join(path[1:], ".")}
+		stat.Description, _ = node["desc"].(string)
+		switch value := node["value"].(type) {
+		case float64:
+			stat.Current = value
+		case map[string]interface{}: // histogram
+			stat.Mean, _ = value["arithmetic_mean"].(float64)
+			stat.Min, _ = value["min"].(float64)
+			stat.Max, _ = value["max"].(float64)
+			stat.Stddev, _ = value["standard_deviation"].(float64)
+			stat.Current = stat.Mean
+		}
+		*stats = append(*stats, stat)
+		return
+	}
+	for key, child := range node {
+		if childNode, ok := child.(map[string]interface{}); ok {
+			flattenNodeStats(append(path[:len(path):len(path)], key), childNode, stats)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestStatsSince(t *testing.T) {
+	prev := Stats{
+		{Section: "httpd", SubSection: "requests", Current: 100},
+		{Section: "couchdb", SubSection: "database_reads", Current: 10},
+	}
+	stats := Stats{
+		{Section: "httpd", SubSection: "requests", Current: 150},
+		{Section: "couchdb", SubSection: "database_reads", Current: 30},
+		{Section: "couchdb", SubSection: "database_writes", Current: 5},
+	}
+	deltas := stats.Since(prev, 5*time.Second)
+	expected := []struct {
+		name        string
+		delta, rate float64
+	}{
+		{"couchdb:database_reads", 20, 4},
+		{"couchdb:database_writes", 0, 0},
+		{"httpd:requests", 50, 10},
+	}
+	if len(deltas) != len(expected) {
+		t.Fatalf("Expected %d deltas, Actual: %d", len(expected), len(deltas))
+	}
+	for i, e := range expected {
+		d := deltas[i]
+		if d.Section+":"+d.SubSection != e.name || d.Delta != e.delta || d.Rate != e.rate {
+			t.Fatalf("Expected: %s %v %v, Actual: %s:%s %v %v", e.name, e.delta, e.rate, d.Section, d.SubSection, d.Delta, d.Rate)
+		}
+	}
+}
+
+func TestGetNodeStats(t *testing.T) {
+	body := `{"couchdb":{"request_time":{"value":{"min":1,"max":9,"arithmetic_mean":4},"type":"histogram","desc":"length of a request"},
+"httpd":{"requests":{"value":12,"type":"counter","desc":"number of HTTP requests"}}}}`
+	ts, couchdb := newTestingServer(200, body)
+	defer ts.Close()
+	stats, err := couchdb.getNodeStats("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(stats)
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 stats, Actual: %d (%#v)", len(stats), stats)
+	}
+	if stats[0].SubSection != "httpd.requests" || stats[0].Current != 12 {
+		t.Fatalf("Counter incorrect: %#v", stats[0])
+	}
+	if stats[1].SubSection != "request_time" || stats[1].Mean != 4 || stats[1].Max != 9 {
+		t.Fatalf("Histogram incorrect: %#v", stats[1])
+	}
+}
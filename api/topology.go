@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/awilliams/couchdb-utils/util"
+	"sort"
+)
+
+// Topology is the desired set of replications kept in the _replicator database
+type Topology struct {
+	Replications []ReplicationConfig `json:"replications"`
+}
+
+const (
+	PlanCreate    = "create"
+	PlanUpdate    = "update"
+	PlanDelete    = "delete"
+	PlanUnchanged = "unchanged"
+)
+
+type ReplicationAction struct {
+	Action   string
+	Config   ReplicationConfig
+	Existing *Replicator
+}
+
+func (a ReplicationAction) PP(printer util.Printer) {
+	printer.Print("%-9s [%s] %s → %s", a.Action, a.Config.ID, a.Config.Source, a.Config.Target)
+}
+
+type ReplicationPlan []ReplicationAction
+
+func (p ReplicationPlan) PP(printer util.Printer) {
+	for _, action := range p {
+		action.PP(printer)
+	}
+}
+
+// Changes returns the actions which modify the _replicator database
+func (p ReplicationPlan) Changes() ReplicationPlan {
+	var changes ReplicationPlan
+	for _, action := range p {
+		if action.Action != PlanUnchanged {
+			changes = append(changes, action)
+		}
+	}
+	return changes
+}
+
+// comparable returns the part of the config that defines the replication,
+// ignoring document metadata and the owner's user context
+func (r ReplicationConfig) comparable() ([]byte, error) {
+	r.ID = ""
+	r.REV = ""
+	r.UserCtx = UserCtx{}
+	return json.Marshal(r)
+}
+
+func (r ReplicationConfig) sameAs(other ReplicationConfig) (bool, error) {
+	a, err := r.comparable()
+	if err != nil {
+		return false, err
+	}
+	b, err := other.comparable()
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(a, b), nil
+}
+
+// PlanTopology compares the desired topology with the replicators on the server.
+// Replications without an id are given one using the IdStrategy.
+func (c *Couchdb) PlanTopology(topology Topology) (ReplicationPlan, error) {
+	var plan ReplicationPlan
+	replicators, err := c.GetReplicators()
+	if err != nil {
+		return plan, err
+	}
+	desired := make(map[string]bool)
+	for _, conf := range topology.Replications {
+		if !conf.hasId() {
+			c.generateId(&conf)
+		}
+		desired[conf.ID] = true
+		existing, found := replicators.findById(conf.ID)
+		if !found {
+			plan = append(plan, ReplicationAction{Action: PlanCreate, Config: conf})
+			continue
+		}
+		same, err := conf.sameAs(existing.ReplicationConfig)
+		if err != nil {
+			return plan, err
+		}
+		action := ReplicationAction{Action: PlanUnchanged, Config: conf, Existing: existing}
+		if !same {
+			action.Action = PlanUpdate
+		}
+		plan = append(plan, action)
+	}
+	var stale ReplicationPlan
+	for _, subReplicators := range *replicators {
+		for _, replicator := range subReplicators {
+			if desired[replicator.ID] {
+				continue
+			}
+			stale = append(stale, ReplicationAction{Action: PlanDelete, Config: replicator.ReplicationConfig, Existing: replicator})
+		}
+	}
+	sort.Sort(byReplicatorId(stale))
+	return append(plan, stale...), nil
+}
+
+// ApplyPlan performs the actions of the plan in order, stopping at the first error.
+// Triggered replicators can't be updated, so they are deleted and recreated.
+func (c *Couchdb) ApplyPlan(plan ReplicationPlan, userCtx UserCtx) (ReplicationPlan, error) {
+	var applied ReplicationPlan
+	for _, action := range plan {
+		conf := action.Config
+		conf.UserCtx = userCtx
+		var err error
+		switch action.Action {
+		case PlanCreate:
+			conf.REV = ""
+			err = c.Replicate(conf)
+		case PlanUpdate:
+			if action.Existing.ReplicationState == "triggered" {
+				// triggered replicators can't be updated
+				err = c.replaceReplicator(action.Existing, conf)
+			} else {
+				conf.REV = action.Existing.REV
+				err = c.Replicate(conf)
+			}
+		case PlanDelete:
+			err = c.DeleteReplicator(conf.ID)
+		default:
+			continue
+		}
+		if err != nil {
+			return applied, err
+		}
+		applied = append(applied, action)
+	}
+	return applied, nil
+}
+
+type byReplicatorId ReplicationPlan
+
+func (a byReplicatorId) Len() int           { return len(a) }
+func (a byReplicatorId) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byReplicatorId) Less(i, j int) bool { return a[i].Config.ID < a[j].Config.ID }
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"github.com/awilliams/couchdb-utils/api/couchtest"
+	"strings"
+	"testing"
+)
+
+const replicatorDocsBody = `{"total_rows":4,"offset":0,"rows":[
+{"id":"_design/_replicator","doc":{"_id":"_design/_replicator"}},
+{"id":"keep","doc":{"_id":"keep","_rev":"1-a","source":"http://remote/a","target":"a","continuous":true,"create_target":false,"cancel":false,"_replication_id":"r1","_replication_state":"triggered"}},
+{"id":"change","doc":{"_id":"change","_rev":"2-b","source":"http://remote/b","target":"b","continuous":false,"create_target":false,"cancel":false,"_replication_id":"r2"}},
+{"id":"stale","doc":{"_id":"stale","_rev":"3-c","source":"http://remote/c","target":"c","continuous":true,"create_target":false,"cancel":false,"_replication_id":"r3"}}
+]}`
+
+func TestPlanTopology(t *testing.T) {
+	ts, couchdb := newTestingServer(200, replicatorDocsBody)
+	defer ts.Close()
+	topology := Topology{Replications: []ReplicationConfig{
+		{ID: "keep", Source: ReplicationEndpoint{URL: "http://remote/a"}, Target: ReplicationEndpoint{URL: "a"}, Continuous: true},
+		{ID: "change", Source: ReplicationEndpoint{URL: "http://remote/b"}, Target: ReplicationEndpoint{URL: "b"}, Continuous: true},
+		{Source: ReplicationEndpoint{URL: "http://remote/d"}, Target: ReplicationEndpoint{URL: "d"}},
+	}}
+	plan, err := couchdb.PlanTopology(topology)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct{ action, id string }{
+		{PlanUnchanged, "keep"},
+		{PlanUpdate, "change"},
+		{PlanCreate, ""},
+		{PlanDelete, "stale"},
+	}
+	if len(plan) != len(expected) {
+		t.Fatalf("Expected %d actions, Actual: %d (%#v)", len(expected), len(plan), plan)
+	}
+	for i, e := range expected {
+		if plan[i].Action != e.action {
+			t.Fatalf("Action %d incorrect. Expected: %s, Actual: %s", i, e.action, plan[i].Action)
+		}
+		if e.id != "" && plan[i].Config.ID != e.id {
+			t.Fatalf("ID %d incorrect. Expected: %s, Actual: %s", i, e.id, plan[i].Config.ID)
+		}
+	}
+	if plan[2].Config.ID == "" {
+		t.Fatal("Expected an id to be generated for new replication")
+	}
+	if plan[1].Existing.REV != "2-b" {
+		t.Fatalf("Existing rev incorrect. Expected: %s, Actual: %s", "2-b", plan[1].Existing.REV)
+	}
+	if len(plan.Changes()) != 3 {
+		t.Fatalf("Expected 3 changes, Actual: %d", len(plan.Changes()))
+	}
+}
+
+func TestApplyPlanTriggeredUpdate(t *testing.T) {
+	s := couchtest.NewServer()
+	defer s.Close()
+	if _, err := s.PutDoc("_replicator", `{"_id":"rep1","source":"http://remote/a","target":"a","_replication_state":"triggered"}`); err != nil {
+		t.Fatal(err)
+	}
+	couchdb, _ := New(s.URL)
+	existing, err := couchdb.GetReplicator("rep1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := existing.ReplicationConfig
+	conf.Continuous = true
+	plan := ReplicationPlan{{Action: PlanUpdate, Config: conf, Existing: existing}}
+
+	// the old config is kept paused if the update can't be created
+	s.Fail("PUT", "/_replicator/rep1", 500)
+	if _, err = couchdb.ApplyPlan(plan, UserCtx{}); err == nil || !strings.Contains(err.Error(), "kept paused") {
+		t.Fatalf("Expected the replicator to be kept paused, Actual: %v", err)
+	}
+	if doc, found := s.Doc("_replicator", "_local/paused-rep1"); !found || !strings.Contains(doc, `"continuous":false`) {
+		t.Fatalf("Expected the old config to be kept, Actual: %s", doc)
+	}
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"github.com/awilliams/couchdb-utils/util"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Timings is the breakdown of the time taken by a request. DNS, Connect and
+// TLS are zero when a connection is reused.
+type Timings struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration // time to first byte, from the start of the request
+	Total   time.Duration // including reading the response body
+}
+
+func (t Timings) String() string {
+	return fmt.Sprintf("dns %v, connect %v, tls %v, ttfb %v, total %v", t.DNS, t.Connect, t.TLS, t.TTFB, t.Total)
+}
+
+// Exchange is a request and its response as seen by the Tracer.
+// Credentials are redacted from the url, headers and bodies.
+type Exchange struct {
+	Started         time.Time
+	Method          string
+	URL             string
+	Proto           string
+	RequestHeaders  http.Header
+	RequestBody     []byte
+	StatusCode      int
+	Status          string
+	ResponseHeaders http.Header
+	ResponseBody    []byte
+	Timings         Timings
+	Error           error
+}
+
+func (e Exchange) PP(printer util.Printer) {
+	printer.Print("> %s %s", e.Method, e.URL)
+	printHeaders(printer, "> ", e.RequestHeaders)
+	if len(e.RequestBody) > 0 {
+		printer.Print("> (%d bytes)", len(e.RequestBody))
+		printBody(printer, e.RequestHeaders, e.RequestBody)
+	}
+	if e.Error != nil {
+		printer.Print("< %s", e.Error)
+	} else {
+		printer.Print("< %s", e.Status)
+		printHeaders(printer, "< ", e.ResponseHeaders)
+		printer.Print("< (%d bytes)", len(e.ResponseBody))
+		printBody(printer, e.ResponseHeaders, e.ResponseBody)
+	}
+	printer.Print("* %s", e.Timings)
+}
+
+func printHeaders(printer util.Printer, prefix string, headers http.Header) {
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		printer.Print("%s%s: %s", prefix, name, strings.Join(headers[name], ", "))
+	}
+}
+
+// isText returns true for the content types which can be printed
+func isText(headers http.Header) bool {
+	contentType := headers.Get("Content-Type")
+	return contentType == "" || strings.HasPrefix(contentType, JSONCONTENTTYPE) || strings.HasPrefix(contentType, "text/")
+}
+
+func printBody(printer util.Printer, headers http.Header, body []byte) {
+	if len(body) > 0 && isText(headers) {
+		printer.Print("%s", bytes.TrimRight(body, "\n"))
+	}
+}
+
+// Tracer is a http.RoundTripper passing each exchange to the Handler once its
+// response body has been read. Bodies are buffered in memory, so tracing isn't
+// suited to large attachments.
+type Tracer struct {
+	Transport http.RoundTripper // defaults to http.DefaultTransport
+	Handler   func(*Exchange)
+}
+
+func (t *Tracer) RoundTrip(req *http.Request) (*http.Response, error) {
+	exchange := &Exchange{
+		Started:        time.Now(),
+		Method:         req.Method,
+		URL:            sanitizePath(req.URL.String()),
+		Proto:          req.Proto,
+		RequestHeaders: redactHeaders(req.Header),
+	}
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		exchange.RequestBody = redactBody(req.URL.Path, body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	var dnsStart, connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { exchange.Timings.DNS = time.Since(dnsStart) },
+		ConnectStart:      func(string, string) { connectStart = time.Now() },
+		ConnectDone:       func(string, string, error) { exchange.Timings.Connect = time.Since(connectStart) },
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { exchange.Timings.TLS = time.Since(tlsStart) },
+		GotFirstResponseByte: func() {
+			exchange.Timings.TTFB = time.Since(exchange.Started)
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		exchange.Error = err
+		t.handle(exchange)
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	exchange.StatusCode = resp.StatusCode
+	exchange.Status = resp.Status
+	exchange.Proto = resp.Proto
+	exchange.ResponseHeaders = redactHeaders(resp.Header)
+	exchange.ResponseBody = redactBody(req.URL.Path, body)
+	exchange.Error = err
+	t.handle(exchange)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func (t *Tracer) handle(exchange *Exchange) {
+	exchange.Timings.Total = time.Since(exchange.Started)
+	if t.Handler != nil {
+		t.Handler(exchange)
+	}
+}
+
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+func redactHeaders(headers http.Header) http.Header {
+	redacted := headers.Clone()
+	for _, name := range redactedHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "***")
+		}
+	}
+	return redacted
+}
+
+var (
+	bodyCredentialsRegex = regexp.MustCompile(`://([^:/@"\s]+):[^@"\s]+@`)
+	bodySecretRegex      = regexp.MustCompile(`"(password|password_sha|derived_key|salt|(?i:authorization|proxy-authorization|cookie|set-cookie))"(\s*):(\s*)"[^"]*"`)
+	bodyAdminHashRegex   = regexp.MustCompile(`"-(pbkdf2|hashed)-[^"]*"`)
+	adminConfigPathRegex = regexp.MustCompile(`(^|/)_config/admins/`)
+)
+
+// redactBody hides the credentials of urls (eg: replication sources), the
+// passwords of user documents, the headers of replication endpoints and the
+// admin passwords of the config. The path is that of the request.
+func redactBody(path string, body []byte) []byte {
+	if adminConfigPathRegex.MatchString(path) && len(body) > 0 {
+		// an admin's password or hash
+		return []byte(`"***"`)
+	}
+	body = bodyCredentialsRegex.ReplaceAll(body, []byte("://$1:***@"))
+	body = bodySecretRegex.ReplaceAll(body, []byte(`"$1"$2:$3"***"`))
+	return bodyAdminHashRegex.ReplaceAll(body, []byte(`"-$1-***"`))
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/awilliams/couchdb-utils/util"
+	"net/url"
+	"strings"
+)
+
+const userIdPrefix = "org.couchdb.user:"
+
+// User is a document of the _users database. The password is hashed by the
+// server on save, the hash fields must be kept when updating the document.
+type User struct {
+	ID  string `json:"_id"`
+	REV string `json:"_rev,omitempty"`
+	UserCtx
+	Type           string `json:"type"`
+	Password       string `json:"password,omitempty"`
+	PasswordScheme string `json:"password_scheme,omitempty"`
+	Iterations     int    `json:"iterations,omitempty"`
+	DerivedKey     string `json:"derived_key,omitempty"`
+	Salt           string `json:"salt,omitempty"`
+	PasswordSha    string `json:"password_sha,omitempty"` // 1.x before 1.3
+	extra          extraFields
+}
+
+func (u *User) UnmarshalJSON(data []byte) error {
+	type user User // avoid recursion
+	extra, err := unmarshalWithExtra(data, (*user)(u))
+	u.extra = extra
+	return err
+}
+
+// MarshalJSON keeps the fields of the document which User doesn't declare
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User // avoid recursion
+	return marshalWithExtra(user(u), u.extra)
+}
+
+func NewUser(name, password string, roles []string) User {
+	if roles == nil {
+		roles = []string{}
+	}
+	return User{
+		ID:       userIdPrefix + name,
+		UserCtx:  UserCtx{Name: name, Roles: roles},
+		Type:     "user",
+		Password: password,
+	}
+}
+
+func (u User) PP(printer util.Printer) {
+	printer.Print("%s\t%s", u.Name, strings.Join(u.Roles, ", "))
+}
+
+func (u User) path() string {
+	if u.REV == "" {
+		return "_users/" + escapeDocId(u.ID)
+	} else {
+		return "_users/" + escapeDocId(u.ID) + "?rev=" + url.QueryEscape(u.REV)
+	}
+}
+
+type Users []User
+
+func (u Users) PP(printer util.Printer) {
+	for _, user := range u {
+		user.PP(printer)
+	}
+}
+
+type userDocs struct {
+	Rows []struct {
+		Id  string `json:"id"`
+		Doc User   `json:"doc"`
+	} `json:"rows"`
+}
+
+func (u userDocs) path() string {
+	startkey := url.QueryEscape(`"` + userIdPrefix + `"`)
+	endkey := url.QueryEscape(`"` + userIdPrefix + "\ufff0" + `"`)
+	return "_users/_all_docs?include_docs=true&startkey=" + startkey + "&endkey=" + endkey
+}
+
+func (c *Couchdb) GetUsers() (Users, error) {
+	var users Users
+	docs := new(userDocs)
+	err := c.getJson(docs, docs.path())
+	if err != nil {
+		return users, err
+	}
+	for _, row := range docs.Rows {
+		users = append(users, row.Doc)
+	}
+	return users, nil
+}
+
+func (c *Couchdb) GetUser(name string) (User, error) {
+	user := User{ID: userIdPrefix + name}
+	err := c.getJson(&user, user.path())
+	return user, err
+}
+
+// SaveUser creates the user, or updates it if REV is set
+func (c *Couchdb) SaveUser(user User) error {
+	jsonBody, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	// the rev is in the body
+	return c.putJson(new(interface{}), bytes.NewReader(jsonBody), "_users/"+escapeDocId(user.ID))
+}
+
+func (c *Couchdb) DeleteUser(name string) error {
+	user, err := c.GetUser(name)
+	if err != nil {
+		return err
+	}
+	return c.deleteJson(new(interface{}), user.path())
+}
+
+// SetPassword changes the password of the user, which is hashed by the server
+func (c *Couchdb) SetPassword(name, password string) error {
+	user, err := c.GetUser(name)
+	if err != nil {
+		return err
+	}
+	user.Password = password
+	// drop the old hash so the server computes a new one
+	user.DerivedKey, user.Salt, user.PasswordSha, user.PasswordScheme, user.Iterations = "", "", "", "", 0
+	return c.SaveUser(user)
+}
+
+func (c *Couchdb) SetRoles(name string, roles []string) error {
+	user, err := c.GetUser(name)
+	if err != nil {
+		return err
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	user.Roles = roles
+	return c.SaveUser(user)
+}
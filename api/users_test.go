@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewUserJson(t *testing.T) {
+	data, err := json.Marshal(NewUser("bob", "secret", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"_id":"org.couchdb.user:bob","name":"bob","roles":[],"type":"user","password":"secret"}`
+	if string(data) != expected {
+		t.Fatalf("Expected: %s, Actual: %s", expected, data)
+	}
+}
+
+func TestSetRolesKeepsPassword(t *testing.T) {
+	var saved map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &saved)
+			w.WriteHeader(201)
+		}
+		fmt.Fprint(w, `{"_id":"org.couchdb.user:bob","_rev":"1-a","name":"bob","roles":[],"type":"user","password_scheme":"pbkdf2","iterations":10,"derived_key":"abc","salt":"def","email":"bob@example.com","profile":{"age":3}}`)
+	}))
+	defer ts.Close()
+	couchdb, _ := New(ts.URL)
+	if err := couchdb.SetRoles("bob", []string{"admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if saved["derived_key"] != "abc" || saved["salt"] != "def" || saved["_rev"] != "1-a" {
+		t.Fatalf("Password hash or rev not kept: %#v", saved)
+	}
+	if profile, _ := saved["profile"].(map[string]interface{}); saved["email"] != "bob@example.com" || profile["age"] != 3.0 {
+		t.Fatalf("Custom fields not kept: %#v", saved)
+	}
+	roles, _ := saved["roles"].([]interface{})
+	if len(roles) != 1 || roles[0] != "admin" {
+		t.Fatalf("Roles incorrect: %#v", saved["roles"])
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"github.com/awilliams/couchdb-utils/api"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// attachmentFile returns dir/<docid>/<name>, refusing paths which would escape dir
+func attachmentFile(dir string, attachment api.Attachment) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(attachment.DocId), filepath.FromSlash(attachment.Name))
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Attachment %s/%s is outside of %s", attachment.DocId, attachment.Name, dir)
+	}
+	return path, nil
+}
+
+// saveAttachment streams the attachment into the given file
+func saveAttachment(db api.Database, docId, name, path string) error {
+	body, err := Couchdb().GetAttachment(db, docId, name)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	var out io.Writer = os.Stdout
+	if path != "-" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	_, err = io.Copy(out, body)
+	return err
+}
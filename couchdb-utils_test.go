@@ -1,3 +1,54 @@
 package main
 
-// nothing for now
+import (
+	"github.com/awilliams/couchdb-utils/api/couchtest"
+	"testing"
+)
+
+// useServer points the commands at the fake server
+func useServer(t *testing.T) *couchtest.Server {
+	s := couchtest.NewServer()
+	GlobalConfig.Host = s.URL
+	replicatorBaseConf.IdScheme = "legacy"
+	couchdb = nil
+	t.Cleanup(func() {
+		s.Close()
+		couchdb = nil
+	})
+	return s
+}
+
+func TestParseDatabases(t *testing.T) {
+	s := useServer(t)
+	s.CreateDatabase("orders")
+	s.CreateDatabase("orders/eu")
+	s.CreateDatabase("users")
+	t.Cleanup(func() {
+		databaseSelection = databaseFlags{}
+	})
+
+	databases := parseDatabases(nil)
+	if len(databases) != 3 || databases[0].String() != "orders" {
+		t.Fatalf("Expected all but system databases, Actual: %v", databases)
+	}
+	databases = parseDatabases([]string{"a", "b"})
+	if len(databases) != 2 || databases[0].String() != "a" || databases[1].String() != "b" {
+		t.Fatalf("Expected given databases, Actual: %v", databases)
+	}
+	if requests := s.Requests(); len(requests) != 1 || requests[0] != "GET /_all_dbs" {
+		t.Fatalf("Requests incorrect: %v", requests)
+	}
+
+	databaseSelection = databaseFlags{Match: "orders*", Exclude: "*/eu"}
+	if databases = parseDatabases(nil); len(databases) != 1 || databases[0].String() != "orders" {
+		t.Fatalf("Expected matching databases, Actual: %v", databases)
+	}
+	databaseSelection = databaseFlags{Regex: "^_", IncludeSystem: true}
+	if databases = parseDatabases(nil); len(databases) != 2 || databases[0].String() != "_replicator" {
+		t.Fatalf("Expected system databases, Actual: %v", databases)
+	}
+	databaseSelection = databaseFlags{Match: "a", Regex: "a"}
+	if _, err := databaseSelection.filter(); err == nil {
+		t.Fatal("Expected error using --match and --regex")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"github.com/awilliams/cobra"
+	"github.com/awilliams/couchdb-utils/api"
+)
+
+// databaseFlags selects the databases of the commands acting on all databases,
+// eg: `views`, `refreshviews` and `rep host`
+type databaseFlags struct {
+	Match         string
+	Regex         string
+	Exclude       string
+	IncludeSystem bool
+}
+
+func (f *databaseFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&f.Match, "match", "", "", "only databases matching this glob, eg: 'orders_*'")
+	cmd.Flags().StringVarP(&f.Regex, "regex", "", "", "only databases matching this regex")
+	cmd.Flags().StringVarP(&f.Exclude, "exclude", "", "", "skip databases matching this glob")
+	cmd.Flags().BoolVarP(&f.IncludeSystem, "include-system", "", false, "include the databases beginning with '_', eg: _users")
+}
+
+func (f *databaseFlags) filter() (api.DatabaseFilter, error) {
+	filter := api.DatabaseFilter{IncludeSystem: f.IncludeSystem}
+	var err error
+	switch {
+	case f.Match != "" && f.Regex != "":
+		return filter, fmt.Errorf("--match and --regex can't be used together")
+	case f.Match != "":
+		filter.Include, err = api.CompileGlob(f.Match)
+	case f.Regex != "":
+		filter.Include, err = compileRegexp(f.Regex)
+	}
+	if err != nil {
+		return filter, err
+	}
+	if f.Exclude != "" {
+		filter.Exclude, err = api.CompileGlob(f.Exclude)
+	}
+	return filter, err
+}
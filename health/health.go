@@ -0,0 +1,247 @@
+// Package health evaluates server health rules, reporting in the format used
+// by Nagios/Icinga plugins.
+package health
+
+import (
+	"fmt"
+	"github.com/awilliams/couchdb-utils/api"
+	"github.com/awilliams/couchdb-utils/util"
+	"strings"
+)
+
+// Status values are the plugin exit codes
+type Status int
+
+const (
+	OK Status = iota
+	Warning
+	Critical
+	Unknown
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "OK"
+	case Warning:
+		return "WARNING"
+	case Critical:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// Thresholds are upper limits of a value, a zero limit is disabled.
+// A rule is skipped when both limits are zero.
+type Thresholds struct {
+	Warning  float64
+	Critical float64
+}
+
+func (t Thresholds) Enabled() bool {
+	return t.Warning > 0 || t.Critical > 0
+}
+
+func (t Thresholds) Evaluate(value float64) Status {
+	if t.Critical > 0 && value >= t.Critical {
+		return Critical
+	}
+	if t.Warning > 0 && value >= t.Warning {
+		return Warning
+	}
+	return OK
+}
+
+func (t Thresholds) perfdata(label string, value float64) string {
+	return fmt.Sprintf("%s=%v;%v;%v", label, value, t.Warning, t.Critical)
+}
+
+type Config struct {
+	ReplicationFailures Thresholds // replicators in error state
+	IndexerBacklog      Thresholds // changes left to index, summed over indexer tasks
+	OpenDatabases       Thresholds
+	RequestTime         Thresholds // mean, in milliseconds
+	Fragmentation       Thresholds // percentage of a database file which is not live data
+	// databases smaller than this many bytes are ignored by the fragmentation rule
+	FragmentationMinSize int64
+}
+
+type Result struct {
+	Name     string
+	Status   Status
+	Message  string
+	Perfdata string
+}
+
+type Results []Result
+
+// Status returns the worst status of the results
+func (r Results) Status() Status {
+	worst := OK
+	for _, result := range r {
+		if result.Status > worst {
+			worst = result.Status
+		}
+	}
+	return worst
+}
+
+// PP prints the plugin output: a summary line with performance data, then a line per rule
+func (r Results) PP(printer util.Printer) {
+	status := r.Status()
+	var messages, perfdata []string
+	for _, result := range r {
+		if result.Status == status {
+			messages = append(messages, result.Message)
+		}
+		if result.Perfdata != "" {
+			perfdata = append(perfdata, result.Perfdata)
+		}
+	}
+	summary := fmt.Sprintf("COUCHDB %s - %s", status, strings.Join(messages, ", "))
+	if len(perfdata) > 0 {
+		summary += " | " + strings.Join(perfdata, " ")
+	}
+	printer.Print(summary)
+	for _, result := range r {
+		printer.Print("[%s] %s: %s", result.Status, result.Name, result.Message)
+	}
+}
+
+func unknown(name string, err error) Result {
+	return Result{Name: name, Status: Unknown, Message: err.Error()}
+}
+
+func threshold(name string, t Thresholds, value float64, format string) Result {
+	return Result{
+		Name:     name,
+		Status:   t.Evaluate(value),
+		Message:  fmt.Sprintf(format, value),
+		Perfdata: t.perfdata(name, value),
+	}
+}
+
+// Check evaluates the rules. The server must be reachable for the other rules to be evaluated.
+func Check(couchdb *api.Couchdb, conf Config) Results {
+	server, err := couchdb.GetServer()
+	if err != nil {
+		return Results{{Name: "server", Status: Critical, Message: "unreachable: " + strings.TrimSpace(err.Error())}}
+	}
+	results := Results{{Name: "server", Status: OK, Message: "reachable " + server.String()}}
+	results = append(results, checkUp(couchdb, server))
+
+	if conf.ReplicationFailures.Enabled() {
+		results = append(results, checkReplications(couchdb, conf.ReplicationFailures))
+	}
+	if conf.IndexerBacklog.Enabled() {
+		results = append(results, checkIndexers(couchdb, conf.IndexerBacklog))
+	}
+	if conf.OpenDatabases.Enabled() {
+		results = append(results, checkStat(couchdb, "open_databases", "open_databases", conf.OpenDatabases, "%v open databases", func(s api.Stat) float64 {
+			return s.Current
+		}))
+	}
+	if conf.RequestTime.Enabled() {
+		results = append(results, checkStat(couchdb, "request_time", "request_time", conf.RequestTime, "%vms mean request time", func(s api.Stat) float64 {
+			return s.Mean
+		}))
+	}
+	if conf.Fragmentation.Enabled() {
+		results = append(results, checkFragmentation(couchdb, conf.Fragmentation, conf.FragmentationMinSize))
+	}
+	return results
+}
+
+func checkUp(couchdb *api.Couchdb, server api.Server) Result {
+	up, err := couchdb.GetUp()
+	if couchErr, ok := api.AsCouchdbError(err); ok {
+		if server.Is1x() && (couchErr.IsNotFound() || couchErr.Status == 400) {
+			// 1.x has no _up endpoint
+			return Result{Name: "up", Status: OK, Message: "_up not supported"}
+		}
+		if couchErr.IsNotFound() {
+			// 2.x answers 404 when the node is in maintenance mode or nolb
+			return Result{Name: "up", Status: Critical, Message: "_up returned HTTP 404, the node is in maintenance mode"}
+		}
+		return Result{Name: "up", Status: Critical, Message: fmt.Sprintf("_up returned HTTP %d", couchErr.Status)}
+	}
+	if err != nil {
+		return unknown("up", err)
+	}
+	if up.Status != "ok" {
+		return Result{Name: "up", Status: Critical, Message: "status " + up.Status}
+	}
+	return Result{Name: "up", Status: OK, Message: "status ok"}
+}
+
+func checkReplications(couchdb *api.Couchdb, t Thresholds) Result {
+	replicators, err := couchdb.GetReplicators()
+	if err != nil {
+		return unknown("replication_failures", err)
+	}
+	var failed []string
+	for _, subReplicators := range *replicators {
+		for _, replicator := range subReplicators {
+			switch replicator.ReplicationState {
+			case "error", "failed", "crashing":
+				failed = append(failed, replicator.ID)
+			}
+		}
+	}
+	result := threshold("replication_failures", t, float64(len(failed)), "%v failed replicators")
+	if len(failed) > 0 {
+		result.Message += " (" + strings.Join(failed, ", ") + ")"
+	}
+	return result
+}
+
+func checkIndexers(couchdb *api.Couchdb, t Thresholds) Result {
+	activeTasks, err := couchdb.GetActiveTasks()
+	if err != nil {
+		return unknown("indexer_backlog", err)
+	}
+	var backlog int
+	for _, task := range activeTasks.ByType("indexer") {
+		backlog += task.TotalChanges - task.ChangesDone
+	}
+	return threshold("indexer_backlog", t, float64(backlog), "%v changes left to index")
+}
+
+func checkStat(couchdb *api.Couchdb, name, subSection string, t Thresholds, format string, value func(api.Stat) float64) Result {
+	stats, err := couchdb.GetStats("couchdb", subSection)
+	if err != nil {
+		return unknown(name, err)
+	}
+	if len(stats) == 0 {
+		return unknown(name, fmt.Errorf("stat couchdb %s not found", subSection))
+	}
+	return threshold(name, t, value(stats[0]), format)
+}
+
+func checkFragmentation(couchdb *api.Couchdb, t Thresholds, minSize int64) Result {
+	databases, err := couchdb.GetDatabases()
+	if err != nil {
+		return unknown("fragmentation", err)
+	}
+	var worst float64
+	var worstDb string
+	for _, db := range databases {
+		info, err := couchdb.GetDatabaseInfo(db)
+		if err != nil {
+			return unknown("fragmentation", err)
+		}
+		size := info.FileSize()
+		if size == 0 || size < minSize {
+			continue
+		}
+		fragmentation := float64(size-info.ActiveSize()) / float64(size) * 100
+		if fragmentation > worst {
+			worst, worstDb = fragmentation, db.String()
+		}
+	}
+	result := threshold("fragmentation", t, float64(int(worst)), "%v%% max fragmentation")
+	if worstDb != "" {
+		result.Message += " (" + worstDb + ")"
+	}
+	return result
+}
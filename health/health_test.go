@@ -0,0 +1,66 @@
+package health
+
+import (
+	"fmt"
+	"github.com/awilliams/couchdb-utils/api"
+	"github.com/awilliams/couchdb-utils/api/couchtest"
+	"strings"
+	"testing"
+)
+
+type bufferPrinter struct {
+	lines []string
+}
+
+func (p *bufferPrinter) Print(format string, args ...interface{}) {
+	p.lines = append(p.lines, fmt.Sprintf(format, args...))
+}
+
+func TestThresholds(t *testing.T) {
+	thresholds := Thresholds{Warning: 10, Critical: 20}
+	cases := map[float64]Status{0: OK, 9.9: OK, 10: Warning, 19: Warning, 20: Critical}
+	for value, expected := range cases {
+		if status := thresholds.Evaluate(value); status != expected {
+			t.Fatalf("Evaluate(%v) incorrect. Expected: %s, Actual: %s", value, expected, status)
+		}
+	}
+	if (Thresholds{}).Enabled() {
+		t.Fatal("Empty thresholds should be disabled")
+	}
+}
+
+func TestResultsOutput(t *testing.T) {
+	results := Results{
+		{Name: "server", Status: OK, Message: "reachable"},
+		threshold("indexer_backlog", Thresholds{Warning: 100, Critical: 1000}, 150, "%v changes left to index"),
+	}
+	if results.Status() != Warning {
+		t.Fatalf("Expected: %s, Actual: %s", Warning, results.Status())
+	}
+	printer := new(bufferPrinter)
+	results.PP(printer)
+	expected := []string{
+		"COUCHDB WARNING - 150 changes left to index | indexer_backlog=150;100;1000",
+		"[OK] server: reachable",
+		"[WARNING] indexer_backlog: 150 changes left to index",
+	}
+	if strings.Join(printer.lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected:\n%s\nActual:\n%s", strings.Join(expected, "\n"), strings.Join(printer.lines, "\n"))
+	}
+}
+
+func TestCheckUp(t *testing.T) {
+	s := couchtest.NewServer()
+	defer s.Close()
+	couchdb, _ := api.New(s.URL)
+	s.Fail("GET", "/_up", 404)
+
+	s.SetVersion("1.6.1")
+	if result := Check(couchdb, Config{})[1]; result.Status != OK {
+		t.Fatalf("A 404 from 1.x should be OK, Actual: %#v", result)
+	}
+	s.SetVersion("2.3.1")
+	if result := Check(couchdb, Config{})[1]; result.Status != Critical {
+		t.Fatalf("A 404 from 2.x should be CRITICAL, Actual: %#v", result)
+	}
+}
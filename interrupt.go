@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/awilliams/couchdb-utils/api"
+	"github.com/awilliams/couchdb-utils/util"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// interrupted is cancelled on SIGINT or SIGTERM, aborting the requests in flight
+var interrupted, interrupt = context.WithCancel(context.Background())
+
+// interruptGrace is how long a command has to stop after being interrupted,
+// before exiting anyway (eg: commands serving or pushing metrics)
+const interruptGrace = 2 * time.Second
+
+// critical is read locked while running operations which must finish after an
+// interrupt, eg: the rollback of `rep host --atomic`. Only a second signal exits
+// before they do.
+var critical sync.RWMutex
+
+// runCritical runs f, delaying the exit after an interrupt until it returns
+func runCritical(f func()) {
+	critical.RLock()
+	defer critical.RUnlock()
+	f()
+}
+
+// requestSummary counts the completed requests by method and status code
+type requestSummary struct {
+	sync.Mutex
+	counts map[string]int
+	total  int
+}
+
+func (s *requestSummary) add(result api.Result) {
+	if result.StatusCode == 0 {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.counts == nil {
+		s.counts = make(map[string]int)
+	}
+	s.counts[fmt.Sprintf("%s %d", result.Method, result.StatusCode)]++
+	s.total++
+}
+
+func (s *requestSummary) PP(printer util.Printer) {
+	s.Lock()
+	defer s.Unlock()
+	printer.Print("Interrupted after %d completed requests", s.total)
+	var keys []string
+	for key := range s.counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		printer.Print(" %s: %d", key, s.counts[key])
+	}
+}
+
+var completedRequests requestSummary
+
+var exitOnce sync.Once
+
+// exitInterrupted prints what completed and exits with the conventional 128+SIGINT
+func exitInterrupted() {
+	exitOnce.Do(func() {
+		util.PrettyPrint(&completedRequests)
+		exit(130)
+	})
+}
+
+// handleInterrupts cancels the requests in flight on the first SIGINT/SIGTERM,
+// and exits after a second one or once interruptGrace has passed and the
+// critical operations have finished
+func handleInterrupts() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		interrupt()
+		graceOver := make(chan struct{})
+		go func() {
+			<-time.After(interruptGrace)
+			critical.Lock()
+			close(graceOver)
+		}()
+		select {
+		case <-signals:
+		case <-graceOver:
+		}
+		exitInterrupted()
+	}()
+}
+
+// sleep waits for the duration, exiting if interrupted meanwhile
+func sleep(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-interrupted.Done():
+		exitInterrupted()
+	}
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/awilliams/couchdb-utils/api"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Metric is a value with a dotted path, as used by Graphite and StatsD
+type Metric struct {
+	Path  string
+	Value float64
+}
+
+type Metrics []Metric
+
+var invalidPathChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func metricPath(prefix string, parts ...interface{}) string {
+	path := prefix
+	for _, part := range parts {
+		if path != "" {
+			path += "."
+		}
+		path += invalidPathChars.ReplaceAllString(fmt.Sprint(part), "_")
+	}
+	return path
+}
+
+// Flatten converts the stats into Section.SubSection.current/mean/max metrics
+// and counts the active tasks by type as active_tasks.<type>
+func Flatten(prefix string, stats api.Stats, activeTasks api.ActiveTasks) Metrics {
+	var metrics Metrics
+	for _, stat := range stats {
+		metrics = append(metrics,
+			Metric{metricPath(prefix, stat.Section, stat.SubSection, "current"), stat.Current},
+			Metric{metricPath(prefix, stat.Section, stat.SubSection, "mean"), stat.Mean},
+			Metric{metricPath(prefix, stat.Section, stat.SubSection, "max"), stat.Max})
+	}
+	counts := make(map[string]int)
+	for _, task := range activeTasks {
+		counts[task.Type]++
+	}
+	for taskType, count := range counts {
+		metrics = append(metrics, Metric{metricPath(prefix, "active_tasks", taskType), float64(count)})
+	}
+	metrics = append(metrics, Metric{metricPath(prefix, "active_tasks", "total"), float64(len(activeTasks))})
+	return metrics
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// WriteGraphite writes the metrics in the Graphite plaintext protocol
+func WriteGraphite(w io.Writer, metrics Metrics, timestamp time.Time) error {
+	for _, metric := range metrics {
+		if _, err := fmt.Fprintf(w, "%s %s %d\n", metric.Path, formatValue(metric.Value), timestamp.Unix()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteStatsd writes the metrics as StatsD gauges
+func WriteStatsd(w io.Writer, metrics Metrics) error {
+	for _, metric := range metrics {
+		// one metric per write, each is sent as its own udp packet
+		if _, err := fmt.Fprintf(w, "%s:%s|g\n", metric.Path, formatValue(metric.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pusher sends the server stats to Graphite (over tcp) or StatsD (over udp)
+type Pusher struct {
+	couchdb  *api.Couchdb
+	Prefix   string
+	Graphite string // host:port
+	Statsd   string // host:port
+}
+
+func NewPusher(couchdb *api.Couchdb) *Pusher {
+	return &Pusher{couchdb: couchdb}
+}
+
+// Push collects the stats and active tasks and sends them once
+func (p *Pusher) Push() error {
+	stats, err := p.couchdb.GetStats("", "")
+	if err != nil {
+		return err
+	}
+	activeTasks, err := p.couchdb.GetActiveTasks()
+	if err != nil {
+		return err
+	}
+	metrics := Flatten(p.Prefix, stats, activeTasks)
+	if p.Graphite != "" {
+		conn, err := net.Dial("tcp", p.Graphite)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return WriteGraphite(conn, metrics, time.Now())
+	}
+	conn, err := net.Dial("udp", p.Statsd)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return WriteStatsd(conn, metrics)
+}
+
+// Run pushes every interval, forever. Errors are passed to errorHandler and don't stop the pusher.
+func (p *Pusher) Run(interval time.Duration, errorHandler func(error)) {
+	for {
+		if err := p.Push(); err != nil && errorHandler != nil {
+			errorHandler(err)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/awilliams/couchdb-utils/api"
+	"testing"
+	"time"
+)
+
+func TestFlattenGraphite(t *testing.T) {
+	stats := api.Stats{{Section: "couchdb", SubSection: "request_time", Current: 2.5, Mean: 3, Max: 10}}
+	activeTasks := api.ActiveTasks{{Type: "indexer"}, {Type: "indexer"}}
+	var buf bytes.Buffer
+	err := WriteGraphite(&buf, Flatten("couchdb.prod", stats, activeTasks), time.Unix(1400000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `couchdb.prod.couchdb.request_time.current 2.5 1400000000
+couchdb.prod.couchdb.request_time.mean 3 1400000000
+couchdb.prod.couchdb.request_time.max 10 1400000000
+couchdb.prod.active_tasks.indexer 2 1400000000
+couchdb.prod.active_tasks.total 2 1400000000
+`
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\nActual:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteStatsd(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteStatsd(&buf, Metrics{{"couchdb.httpd.requests.current", 42}}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "couchdb.httpd.requests.current:42|g\n" {
+		t.Fatalf("Unexpected output: %s", buf.String())
+	}
+}
@@ -0,0 +1,133 @@
+// Package metrics collects server statistics as flat samples which can be
+// exported to monitoring systems.
+package metrics
+
+import (
+	"github.com/awilliams/couchdb-utils/api"
+	"regexp"
+	"strings"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Name   string
+	Help   string
+	Labels []Label
+	Value  float64
+}
+
+type Samples []Sample
+
+func (s *Samples) add(name, help string, value float64, labels ...Label) {
+	*s = append(*s, Sample{Name: name, Help: help, Labels: labels, Value: value})
+}
+
+type Options struct {
+	Databases bool // collect the info of every database
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// metricName joins the parts into a name such as couchdb_httpd_requests
+func metricName(parts ...string) string {
+	return invalidNameChars.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+// Collect gathers samples from the server. Failing parts are skipped and their errors
+// returned; couchdb_up is 0 if the server couldn't be reached at all.
+func Collect(couchdb *api.Couchdb, opts Options) (Samples, []error) {
+	var samples Samples
+	var errors []error
+	if _, err := couchdb.GetServer(); err != nil {
+		samples.add("couchdb_up", "Whether the server could be reached", 0)
+		return samples, []error{err}
+	}
+	samples.add("couchdb_up", "Whether the server could be reached", 1)
+
+	if stats, err := couchdb.GetStats("", ""); err != nil {
+		errors = append(errors, err)
+	} else {
+		samples = append(samples, statSamples(stats)...)
+	}
+
+	if activeTasks, err := couchdb.GetActiveTasks(); err != nil {
+		errors = append(errors, err)
+	} else {
+		counts := make(map[string]int)
+		for _, task := range activeTasks {
+			counts[task.Type]++
+			samples.add("couchdb_active_task_progress", "Progress percentage of an active task", float64(task.Progress),
+				Label{"type", task.Type}, Label{"database", taskDatabase(task)}, Label{"pid", task.Pid})
+		}
+		for taskType, count := range counts {
+			samples.add("couchdb_active_tasks", "Number of active tasks by type", float64(count), Label{"type", taskType})
+		}
+	}
+
+	if replicators, err := couchdb.GetReplicators(); err != nil {
+		errors = append(errors, err)
+	} else {
+		counts := make(map[string]int)
+		for _, subReplicators := range *replicators {
+			for _, replicator := range subReplicators {
+				state := replicator.ReplicationState
+				if state == "" {
+					state = "unknown"
+				}
+				counts[state]++
+				samples.add("couchdb_replicator_state", "Replicator document state", 1,
+					Label{"id", replicator.ID}, Label{"state", state})
+			}
+		}
+		for state, count := range counts {
+			samples.add("couchdb_replicators", "Number of replicator documents by state", float64(count), Label{"state", state})
+		}
+	}
+
+	if opts.Databases {
+		databases, err := couchdb.GetDatabases()
+		if err != nil {
+			errors = append(errors, err)
+		}
+		for _, db := range databases {
+			info, err := couchdb.GetDatabaseInfo(db)
+			if err != nil {
+				errors = append(errors, err)
+				continue
+			}
+			label := Label{"db", db.String()}
+			samples.add("couchdb_database_doc_count", "Number of documents", float64(info.DocCount), label)
+			samples.add("couchdb_database_doc_del_count", "Number of deleted documents", float64(info.DocDelCount), label)
+			samples.add("couchdb_database_file_size_bytes", "Size of the database file", float64(info.FileSize()), label)
+			samples.add("couchdb_database_active_size_bytes", "Size of the live data", float64(info.ActiveSize()), label)
+		}
+	}
+	return samples, errors
+}
+
+// statSamples converts each stat into its current value, plus mean and max
+func statSamples(stats api.Stats) Samples {
+	var samples Samples
+	for _, stat := range stats {
+		name := metricName("couchdb", stat.Section, stat.SubSection)
+		var meanHelp, maxHelp string
+		if stat.Description != "" {
+			meanHelp, maxHelp = stat.Description+" (mean)", stat.Description+" (max)"
+		}
+		samples.add(name, stat.Description, stat.Current)
+		samples.add(name+"_mean", meanHelp, stat.Mean)
+		samples.add(name+"_max", maxHelp, stat.Max)
+	}
+	return samples
+}
+
+func taskDatabase(task api.ActiveTask) string {
+	if task.Database.Name != nil {
+		return task.Database.String()
+	}
+	return task.Source
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/awilliams/couchdb-utils/api"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// WritePrometheus writes the samples in the Prometheus text exposition format.
+// Samples of the same name are grouped together.
+func WritePrometheus(w io.Writer, samples Samples) error {
+	var names []string
+	byName := make(map[string]Samples)
+	for _, sample := range samples {
+		if _, found := byName[sample.Name]; !found {
+			names = append(names, sample.Name)
+		}
+		byName[sample.Name] = append(byName[sample.Name], sample)
+	}
+	for _, name := range names {
+		group := byName[name]
+		if help := group[0].Help; help != "" {
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n", name, helpEscaper.Replace(help)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "# TYPE %s gauge\n", name); err != nil {
+			return err
+		}
+		for _, sample := range group {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(sample.Labels), strconv.FormatFloat(sample.Value, 'g', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = fmt.Sprintf(`%s="%s"`, label.Name, labelValueEscaper.Replace(label.Value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// Exporter scrapes the server periodically and serves the latest samples on http
+type Exporter struct {
+	couchdb      *api.Couchdb
+	opts         Options
+	ErrorHandler func(error)
+
+	mutex sync.RWMutex
+	body  []byte
+}
+
+func NewExporter(couchdb *api.Couchdb, opts Options) *Exporter {
+	return &Exporter{couchdb: couchdb, opts: opts}
+}
+
+// Scrape collects the samples and keeps them for ServeHTTP
+func (e *Exporter) Scrape() {
+	start := time.Now()
+	samples, errors := Collect(e.couchdb, e.opts)
+	samples.add("couchdb_scrape_duration_seconds", "Time taken to collect the metrics", time.Since(start).Seconds())
+	samples.add("couchdb_scrape_errors", "Number of requests which failed during the last scrape", float64(len(errors)))
+	if e.ErrorHandler != nil {
+		for _, err := range errors {
+			e.ErrorHandler(err)
+		}
+	}
+	var buf bytes.Buffer
+	WritePrometheus(&buf, samples)
+	e.mutex.Lock()
+	e.body = buf.Bytes()
+	e.mutex.Unlock()
+}
+
+// Run scrapes every interval, forever
+func (e *Exporter) Run(interval time.Duration) {
+	for {
+		e.Scrape()
+		time.Sleep(interval)
+	}
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mutex.RLock()
+	body := e.body
+	e.mutex.RUnlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(body)
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	samples := Samples{
+		{Name: "couchdb_active_tasks", Help: "Number of active tasks by type", Labels: []Label{{"type", "indexer"}}, Value: 2},
+		{Name: "couchdb_up", Value: 1},
+		{Name: "couchdb_active_tasks", Help: "Number of active tasks by type", Labels: []Label{{"type", `a"b`}}, Value: 0.5},
+	}
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, samples); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP couchdb_active_tasks Number of active tasks by type
+# TYPE couchdb_active_tasks gauge
+couchdb_active_tasks{type="indexer"} 2
+couchdb_active_tasks{type="a\"b"} 0.5
+# TYPE couchdb_up gauge
+couchdb_up 1
+`
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\nActual:\n%s", expected, buf.String())
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	if name := metricName("couchdb", "httpd_status_codes", "200"); name != "couchdb_httpd_status_codes_200" {
+		t.Fatalf("Expected: %s, Actual: %s", "couchdb_httpd_status_codes_200", name)
+	}
+	if name := metricName("couchdb", "couch_replicator", "jobs.running"); name != "couchdb_couch_replicator_jobs_running" {
+		t.Fatalf("Expected: %s, Actual: %s", "couchdb_couch_replicator_jobs_running", name)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/awilliams/cobra"
+	"github.com/awilliams/couchdb-utils/api"
+	"strconv"
+	"strings"
+)
+
+// replicationFlags holds the optional replication settings shared by `rep start` and `rep host`
+type replicationFlags struct {
+	Filter             string
+	QueryParams        string
+	Selector           string
+	DocIds             string
+	SinceSeq           string
+	WorkerProcesses    int
+	HttpConnections    int
+	CheckpointInterval int
+	UseCheckpoints     bool
+	SourceHeaders      string
+	SourceAuth         string
+	TargetHeaders      string
+	TargetAuth         string
+}
+
+func (f *replicationFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&f.Filter, "filter", "", "", "filter function (ddoc/name)")
+	cmd.Flags().StringVarP(&f.QueryParams, "query-params", "", "", "json object of parameters passed to the filter function")
+	cmd.Flags().StringVarP(&f.Selector, "selector", "", "", "json mango selector used to filter documents (2.x)")
+	cmd.Flags().StringVarP(&f.DocIds, "doc-ids", "", "", "comma separated list of document ids to replicate")
+	cmd.Flags().StringVarP(&f.SinceSeq, "since-seq", "", "", "start replicating from this source sequence")
+	cmd.Flags().IntVarP(&f.WorkerProcesses, "worker-processes", "", 0, "number of replication workers (server default if 0)")
+	cmd.Flags().IntVarP(&f.HttpConnections, "http-connections", "", 0, "maximum number of http connections (server default if 0)")
+	cmd.Flags().IntVarP(&f.CheckpointInterval, "checkpoint-interval", "", 0, "checkpoint interval in milliseconds (server default if 0)")
+	cmd.Flags().BoolVarP(&f.UseCheckpoints, "use-checkpoints", "", true, "checkpoint replication progress")
+	cmd.Flags().StringVarP(&f.SourceHeaders, "source-headers", "", "", "comma separated headers sent to the source (Name:value,...)")
+	cmd.Flags().StringVarP(&f.SourceAuth, "source-auth", "", "", "basic auth credentials for the source (user:password)")
+	cmd.Flags().StringVarP(&f.TargetHeaders, "target-headers", "", "", "comma separated headers sent to the target (Name:value,...)")
+	cmd.Flags().StringVarP(&f.TargetAuth, "target-auth", "", "", "basic auth credentials for the target (user:password)")
+}
+
+// apply copies the flag values onto conf. Source and target urls must already be set.
+func (f *replicationFlags) apply(conf *api.ReplicationConfig) error {
+	var err error
+	conf.Filter = f.Filter
+	if conf.QueryParams, err = parseJsonObject("query-params", f.QueryParams); err != nil {
+		return err
+	}
+	if conf.Selector, err = parseJsonObject("selector", f.Selector); err != nil {
+		return err
+	}
+	conf.DocIds = splitList(f.DocIds)
+	conf.SinceSeq = parseSeq(f.SinceSeq)
+	conf.WorkerProcesses = f.WorkerProcesses
+	conf.HttpConnections = f.HttpConnections
+	conf.CheckpointInterval = f.CheckpointInterval
+	if !f.UseCheckpoints {
+		conf.UseCheckpoints = &f.UseCheckpoints
+	}
+	if err = applyEndpointFlags(&conf.Source, f.SourceHeaders, f.SourceAuth); err != nil {
+		return err
+	}
+	return applyEndpointFlags(&conf.Target, f.TargetHeaders, f.TargetAuth)
+}
+
+func applyEndpointFlags(endpoint *api.ReplicationEndpoint, headers, auth string) error {
+	for _, header := range splitList(headers) {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid header %q, must be Name:value", header)
+		}
+		if endpoint.Headers == nil {
+			endpoint.Headers = make(map[string]string)
+		}
+		endpoint.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	if auth != "" {
+		parts := strings.SplitN(auth, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid auth, must be user:password")
+		}
+		endpoint.Auth = &api.EndpointAuth{Basic: &api.BasicAuth{Username: parts[0], Password: parts[1]}}
+	}
+	return nil
+}
+
+func parseJsonObject(name, value string) (map[string]interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &obj); err != nil {
+		return nil, fmt.Errorf("Invalid %s json: %s", name, err)
+	}
+	return obj, nil
+}
+
+// parseSeq returns integer sequences (1.x) as numbers and anything else (2.x) as a string
+func parseSeq(seq string) interface{} {
+	if seq == "" {
+		return nil
+	}
+	if n, err := strconv.ParseInt(seq, 10, 64); err == nil {
+		return n
+	}
+	return seq
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
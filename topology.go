@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/awilliams/couchdb-utils/api"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+)
+
+// readTopology parses a yaml (or json) file of the form:
+//
+//	replications:
+//	  - source: http://remote:5984/db
+//	    target: db
+//	    continuous: true
+//
+// Keys are the same as those of a _replicator document.
+func readTopology(path string) (api.Topology, error) {
+	var topology api.Topology
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return topology, err
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return topology, err
+	}
+	// round trip through json so the _replicator json keys are used
+	jsonData, err := json.Marshal(stringKeys(raw))
+	if err != nil {
+		return topology, err
+	}
+	if err := json.Unmarshal(jsonData, &topology); err != nil {
+		return topology, fmt.Errorf("%s: %s", path, err)
+	}
+	return topology, nil
+}
+
+// stringKeys converts the map[interface{}]interface{} values produced by
+// yaml into map[string]interface{}, which encoding/json can handle
+func stringKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			m[fmt.Sprintf("%v", key)] = stringKeys(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range t {
+			t[i] = stringKeys(value)
+		}
+	}
+	return v
+}
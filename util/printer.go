@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 type PrettyPrinter interface {
@@ -20,6 +21,22 @@ func PrettyPrint(printers ...PrettyPrinter) {
 	}
 }
 
+// ClearScreen clears the terminal and moves the cursor to the top, for output which refreshes in place
+func ClearScreen() {
+	fmt.Fprint(*output.writer, "\033[H\033[2J")
+}
+
+// ProgressBar renders a percentage as a bar of the given width, eg: [#####.....]
+func ProgressBar(percent, width int) string {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	filled := percent * width / 100
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
 func PrintError(err error) {
 	errorOutput.Print("Error: %s", err.Error())
 }
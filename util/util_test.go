@@ -1,3 +1,19 @@
 package util
 
-// nothing atm
+import (
+	"testing"
+)
+
+func TestProgressBar(t *testing.T) {
+	cases := map[int]string{
+		0:   "[..........]",
+		45:  "[####......]",
+		100: "[##########]",
+		150: "[##########]",
+	}
+	for percent, expected := range cases {
+		if bar := ProgressBar(percent, 10); bar != expected {
+			t.Fatalf("ProgressBar(%d) incorrect. Expected: %s, Actual: %s", percent, expected, bar)
+		}
+	}
+}